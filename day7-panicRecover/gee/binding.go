@@ -0,0 +1,330 @@
+package gee
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bind fills obj from the request and validates it like ShouldBind, on error it aborts with 400,
+// or 413 when the body is larger than the BodyLimit of the route
+// example:
+//
+//	type Login struct {
+//		User     string `form:"user" json:"user" binding:"required"`
+//		Password string `form:"password" json:"password" binding:"required,min=8"`
+//	}
+func (c *Context) Bind(obj interface{}) error {
+	return c.abortOnBindError(c.ShouldBind(obj))
+}
+
+// BindJSON is Bind for a json body, whatever the Content-Type
+func (c *Context) BindJSON(obj interface{}) error {
+	return c.abortOnBindError(c.ShouldBindJSON(obj))
+}
+
+// BindXML is Bind for an xml body, whatever the Content-Type
+func (c *Context) BindXML(obj interface{}) error {
+	return c.abortOnBindError(c.ShouldBindXML(obj))
+}
+
+// BindQuery is Bind for the query string only
+func (c *Context) BindQuery(obj interface{}) error {
+	return c.abortOnBindError(c.ShouldBindQuery(obj))
+}
+
+// BindUri is Bind for the url params, see ShouldBindUri
+func (c *Context) BindUri(obj interface{}) error {
+	return c.abortOnBindError(c.ShouldBindUri(obj))
+}
+
+// BindHeader is Bind for the request headers, see ShouldBindHeader
+func (c *Context) BindHeader(obj interface{}) error {
+	return c.abortOnBindError(c.ShouldBindHeader(obj))
+}
+
+func (c *Context) abortOnBindError(err error) error {
+	if err != nil && isTooLarge(err) {
+		c.AbortWithError(http.StatusRequestEntityTooLarge, err)
+	} else if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+	}
+
+	return err
+}
+
+// ShouldBind picks the decoder from the Content-Type and fills obj, then validates it with the binding tags.
+// json and xml bodies are decoded with encoding/json and encoding/xml, form, multipart and requests without
+// a body fill the fields from their form tags, query values included. The error is a ValidationErrors
+// when only the validation failed.
+func (c *Context) ShouldBind(obj interface{}) error {
+	switch c.contentType() {
+	case "application/json":
+		return c.ShouldBindJSON(obj)
+	case "application/xml", "text/xml":
+		return c.ShouldBindXML(obj)
+	case "multipart/form-data":
+		if err := c.Req.ParseMultipartForm(c.maxMultipartMemory()); err != nil {
+			return err
+		}
+	default:
+		if err := c.Req.ParseForm(); err != nil {
+			return err
+		}
+	}
+
+	return bindForm(obj, formSource(c.Req.Form).lookup, "form")
+}
+
+// ShouldBindJSON decodes a json body into obj and validates it
+func (c *Context) ShouldBindJSON(obj interface{}) error {
+	return decodeBody(c.Req, obj, func(r io.Reader, v interface{}) error {
+		return json.NewDecoder(r).Decode(v)
+	})
+}
+
+// ShouldBindXML decodes an xml body into obj and validates it
+func (c *Context) ShouldBindXML(obj interface{}) error {
+	return decodeBody(c.Req, obj, func(r io.Reader, v interface{}) error {
+		return xml.NewDecoder(r).Decode(v)
+	})
+}
+
+// ShouldBindQuery fills obj from the query string with the form tags and validates it
+func (c *Context) ShouldBindQuery(obj interface{}) error {
+	return bindForm(obj, formSource(c.Req.URL.Query()).lookup, "form")
+}
+
+// ShouldBindUri fills obj from the url params with the uri tags and validates it
+// example: for /users/:id, struct { ID int `uri:"id" binding:"required"` }
+func (c *Context) ShouldBindUri(obj interface{}) error {
+	return bindForm(obj, func(name string) ([]string, bool) {
+		value, ok := c.Params.Get(name)
+		return []string{value}, ok
+	}, "uri")
+}
+
+// ShouldBindHeader fills obj from the request headers with the header tags and validates it,
+// header names are case insensitive, example: struct { RequestID string `header:"X-Request-Id"` }
+func (c *Context) ShouldBindHeader(obj interface{}) error {
+	return bindForm(obj, func(name string) ([]string, bool) {
+		values := c.Req.Header.Values(name)
+		return values, len(values) > 0
+	}, "header")
+}
+
+// contentType returns the media type of the request, without its parameters
+func (c *Context) contentType() string {
+	mediaType, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	return mediaType
+}
+
+// decodeBody decodes the request body into obj with decode, then validates obj
+func decodeBody(req *http.Request, obj interface{}, decode func(io.Reader, interface{}) error) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return errors.New("gee: empty request body")
+	}
+
+	if err := decode(req.Body, obj); err != nil {
+		return err
+	}
+
+	return Validate(obj)
+}
+
+// formSource looks a name up in url.Values
+type formSource map[string][]string
+
+func (s formSource) lookup(name string) ([]string, bool) {
+	values, ok := s[name]
+	return values, ok
+}
+
+// bindForm fills the struct obj points to with the values lookup finds for the tag names, then validates it
+func bindForm(obj interface{}, lookup func(string) ([]string, bool), tag string) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gee: binding needs a non nil pointer to a struct, got %T", obj)
+	}
+
+	if err := mapFields(v.Elem(), lookup, tag); err != nil {
+		return err
+	}
+
+	return Validate(obj)
+}
+
+// mapFields sets the fields of the struct v from lookup, a field is looked up by its tag name or its
+// Go name, and nested structs are filled field by field. A tag option default=x is used when the name
+// is missing, example: `form:"page,default=1"`
+func mapFields(v reflect.Value, lookup func(string) ([]string, bool), tag string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+
+		field := v.Field(i)
+		if name == "" && isNestedStruct(sf.Type) {
+			if field.Kind() == reflect.Pointer {
+				if field.IsNil() {
+					field.Set(reflect.New(sf.Type.Elem()))
+				}
+				field = field.Elem()
+			}
+			if err := mapFields(field, lookup, tag); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		values, ok := lookup(name)
+		if !ok || len(values) == 0 {
+			def, found := strings.CutPrefix(opts, "default=")
+			if !found {
+				continue
+			}
+			values = []string{def}
+		}
+
+		if err := setField(field, values, sf); err != nil {
+			return fmt.Errorf("gee: field %s: %w", sf.Name, err)
+		}
+	}
+
+	return nil
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isNestedStruct reports whether a field of type t is filled field by field instead of from one value
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// setField sets a field from its values, slices take every value and the other kinds the first one
+func setField(field reflect.Value, values []string, sf reflect.StructField) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value, sf); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setValue(field, values[0], sf)
+}
+
+// setValue parses value into v, times use the time_format tag, RFC 3339 by default
+func setValue(v reflect.Value, value string, sf reflect.StructField) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok && v.Type() != timeType {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch v.Type() {
+	case timeType:
+		if value == "" {
+			return nil
+		}
+		layout := sf.Tag.Get("time_format")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(orZero(value, "false"))
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(orZero(value, "0"), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(orZero(value, "0"), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(orZero(value, "0"), v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		// []byte
+		v.SetBytes([]byte(value))
+	default:
+		return errors.New("unsupported type " + v.Type().String())
+	}
+
+	return nil
+}
+
+// orZero returns zero for an empty value, so an empty form field sets the zero value
+func orZero(value string, zero string) string {
+	if value == "" {
+		return zero
+	}
+
+	return value
+}
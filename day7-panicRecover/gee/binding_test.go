@@ -0,0 +1,232 @@
+package gee
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type signup struct {
+	Name     string    `form:"name" json:"name" xml:"name" binding:"required,min=2,max=10"`
+	Email    string    `form:"email" json:"email" xml:"email" binding:"required,email"`
+	Age      int       `form:"age" json:"age" xml:"age" binding:"min=18"`
+	Plan     string    `form:"plan,default=free" json:"plan" xml:"plan" binding:"oneof=free pro"`
+	Tags     []string  `form:"tag" json:"tags" xml:"tag"`
+	Birthday time.Time `form:"birthday" time_format:"2006-01-02" json:"-" xml:"-"`
+}
+
+func TestShouldBind(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		target      string
+	}{
+		{"query", "GET", "", "", "/?name=geektutu&email=a@b.c&age=20&tag=go&tag=web&birthday=2000-01-02"},
+		{"form", "POST", "application/x-www-form-urlencoded", "name=geektutu&email=a@b.c&age=20&tag=go&tag=web&birthday=2000-01-02", "/"},
+		{"json", "POST", "application/json; charset=utf-8", `{"name":"geektutu","email":"a@b.c","age":20,"plan":"free","tags":["go","web"]}`, "/"},
+		{"xml", "POST", "application/xml", "<signup><name>geektutu</name><email>a@b.c</email><age>20</age><plan>free</plan><tag>go</tag><tag>web</tag></signup>", "/"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		c := &Context{Req: req}
+
+		var s signup
+		if err := c.ShouldBind(&s); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if s.Name != "geektutu" || s.Email != "a@b.c" || s.Age != 20 || s.Plan != "free" || strings.Join(s.Tags, ",") != "go,web" {
+			t.Fatalf("%s: unexpected binding %+v", tt.name, s)
+		}
+
+		if tt.name == "query" && !s.Birthday.Equal(time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("%s: expect the birthday to be parsed with time_format, but got %v", tt.name, s.Birthday)
+		}
+	}
+}
+
+func TestShouldBindMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "geektutu")
+	mw.WriteField("email", "a@b.c")
+	mw.WriteField("age", "20")
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	c := &Context{Req: req}
+
+	var s signup
+	if err := c.ShouldBind(&s); err != nil || s.Name != "geektutu" {
+		t.Fatalf("expect the multipart form to be bound, but got %+v %v", s, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type item struct {
+		SKU string `binding:"required,regex=[A-Z]{3}-[0-9]+"`
+	}
+	type order struct {
+		Items []item `binding:"required,min=1"`
+		Note  string `binding:"max=5"`
+		Code  string `binding:"omitempty,len=4"`
+		Age   int    `binding:"min=18"`
+	}
+
+	err := Validate(&order{Items: []item{{"ABC-1"}, {"abc"}}, Note: "too long", Code: "1234", Age: 20})
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expect 2 field errors, but got %v", err)
+	}
+
+	if errs[0].Field != "Items[1].SKU" || errs[0].Rule != "regex" {
+		t.Fatalf("expect Items[1].SKU to fail on regex, but got %v", errs[0])
+	}
+
+	if errs[1].Field != "Note" || errs[1].Rule != "max" || errs[1].Param != "5" {
+		t.Fatalf("expect Note to fail on max=5, but got %v", errs[1])
+	}
+
+	if err := Validate(&order{Items: []item{{"ABC-1"}}, Age: 20}); err != nil {
+		t.Fatalf("expect empty omitempty fields to be valid, but got %v", err)
+	}
+
+	// the rules run on zero values without omitempty
+	err = Validate(&order{Items: []item{{"ABC-1"}}})
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "Age" || errs[0].Rule != "min" {
+		t.Fatalf("expect an age of 0 to fail on min=18, but got %v", err)
+	}
+
+	if err := Validate(&order{}); err == nil || err.(ValidationErrors)[0].Field != "Items" {
+		t.Fatalf("expect the required Items to fail, but got %v", err)
+	}
+}
+
+func TestBindUriAndHeader(t *testing.T) {
+	type uri struct {
+		ID int `uri:"id" binding:"required"`
+	}
+	type header struct {
+		RequestID string `header:"X-Request-Id" binding:"required"`
+	}
+
+	r := New()
+	r.GET("/users/:id", func(c *Context) {
+		var u uri
+		var h header
+		if c.BindUri(&u) != nil || c.BindHeader(&h) != nil {
+			return
+		}
+		c.String(200, "%d %s", u.ID, h.RequestID)
+	})
+
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	req.Header.Set("x-request-id", "abc")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Body.String() != "42 abc" {
+		t.Fatalf("expect 42 abc, but got %q", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/users/x", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 for an invalid id, but got %d", w.Code)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("gee", 100)
+
+	r := New()
+	r.MaxMultipartMemory = 16 // stream the file to a temp file
+	r.POST("/upload", BodyLimit(1<<10), func(c *Context) {
+		file, err := c.FormFile("file")
+		if c.IsAborted() {
+			return
+		}
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if err := c.SaveUploadedFile(file, filepath.Join(dir, "sub", file.Filename)); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.String(200, "%s %d", file.Filename, file.Size)
+	})
+
+	newUpload := func(size int) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		fw.Write([]byte(content[:size]))
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "/upload", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUpload(len(content)))
+
+	if w.Body.String() != "a.txt 300" {
+		t.Fatalf("expect a.txt 300, but got %d %q", w.Code, w.Body.String())
+	}
+
+	if saved, _ := os.ReadFile(filepath.Join(dir, "sub", "a.txt")); string(saved) != content {
+		t.Fatalf("expect the file to be saved, but got %q", saved)
+	}
+
+	content = strings.Repeat("gee", 1000)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, newUpload(len(content)))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expect 413 for a large body, but got %d", w.Code)
+	}
+
+	// a chunked upload is only stopped while FormFile reads it
+	req := newUpload(len(content))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expect 413 for a large chunked body, but got %d", w.Code)
+	}
+
+	// without a Content-Length the limit is hit while reading
+	r.POST("/bind", BodyLimit(16), func(c *Context) {
+		var s signup
+		c.Bind(&s)
+	})
+	req = httptest.NewRequest("POST", "/bind", strings.NewReader(`{"name":"geektutu","email":"a@b.c"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expect 413 when reading past the limit, but got %d", w.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package gee
+
+import (
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// paramTypes are the named constraints usable as :id<int> or :id{int},
+// anything else between < and > is compiled as a regular expression matching the whole segment
+var paramTypes = map[string]func(string) bool{
+	"int":   isInt,
+	"uint":  isDigits,
+	"alpha": isAlpha,
+	"alnum": isAlnum,
+	"uuid":  isUUID,
+}
+
+// splitParam splits a wildcard segment into its name and constraint
+// example: :id<int> => id, int; :ver{uuid} => ver, uuid; :lang => lang, ""
+func splitParam(seg string) (name string, constraint string, err error) {
+	i := strings.IndexAny(seg, "<{")
+	if i == -1 {
+		return seg[1:], "", nil
+	}
+
+	name, constraint = seg[1:i], seg[i+1:]
+
+	if seg[i] == '<' {
+		if !strings.HasSuffix(constraint, ">") {
+			return name, "", errors.New("unterminated constraint in " + seg)
+		}
+		return name, constraint[:len(constraint)-1], nil
+	}
+
+	if !strings.HasSuffix(constraint, "}") {
+		return name, "", errors.New("unterminated constraint in " + seg)
+	}
+
+	constraint = constraint[:len(constraint)-1]
+	if _, ok := paramTypes[constraint]; !ok {
+		return name, "", errors.New("unknown param type {" + constraint + "} in " + seg)
+	}
+
+	return name, constraint, nil
+}
+
+// paramName returns the name of a wildcard segment, example: :id<int> => id
+func paramName(seg string) string {
+	if i := strings.IndexAny(seg, "<{"); i != -1 {
+		return seg[1:i]
+	}
+
+	return seg[1:]
+}
+
+// compileConstraint returns the matcher of a constraint, nil for an unconstrained param
+func compileConstraint(constraint string) (func(string) bool, error) {
+	if constraint == "" {
+		return nil, nil
+	}
+
+	if match, ok := paramTypes[constraint]; ok {
+		return match, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		return nil, err
+	}
+
+	return re.MatchString, nil
+}
+
+func isInt(s string) bool {
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+
+	return isDigits(s)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isAlpha(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+func isAlnum(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; (c < 'a' || c > 'z') && (s[i] < '0' || s[i] > '9') {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+func isUUID(s string) bool {
+	_, err := ParseUUID(s)
+	return err == nil
+}
+
+// UUID is a 16 byte universally unique identifier, as matched by the uuid param type
+type UUID [16]byte
+
+// ParseUUID parses the canonical form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, in either case
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, errors.New("gee: invalid UUID " + s)
+	}
+
+	j := 0
+	for i := 0; i < len(s); i += 2 {
+		if s[i] == '-' {
+			i++
+		}
+
+		hi, ok1 := fromHex(s[i])
+		lo, ok2 := fromHex(s[i+1])
+		if !ok1 || !ok2 {
+			return UUID{}, errors.New("gee: invalid UUID " + s)
+		}
+
+		u[j] = hi<<4 | lo
+		j++
+	}
+
+	return u, nil
+}
+
+// String returns the canonical lower case form of u
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+
+	return string(buf)
+}
+
+func fromHex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+
+	return 0, false
+}
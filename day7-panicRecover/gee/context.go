@@ -1,41 +1,171 @@
 package gee
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"io"
+	"maps"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
 )
 
+// abortIndex is far beyond any handler chain, so Next never runs a handler once it is set
+const abortIndex = math.MaxInt32 / 2
+
 // H is for json data
 type H map[string]interface{}
 
+// Param is a single url parameter, e.g. {lang go} for /p/:lang
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params is the ordered list of url parameters, kept as a slice so it can be reused between requests
+type Params []Param
+
+// Get returns the value of the first param named name, and whether it exists
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// ByName returns the value of the first param named name, or "" if there is none
+func (ps Params) ByName(name string) string {
+	value, _ := ps.Get(name)
+	return value
+}
+
 // Context is the context of one http request
 type Context struct {
 	// origin objects
-	Writer http.ResponseWriter
-	Req    *http.Request
+	Writer    ResponseWriter
+	Req       *http.Request
+	writermem responseWriter // the writer behind Writer, kept in the Context so it is pooled with it
 	// request info
 	Path   string
 	Method string
-	Params map[string]string
-	// response info
+	Params Params
+	// response info, StatusCode is the last code passed to Status, Writer.Status is the one sent
 	StatusCode int
 	// middleware
 	handlers []HandlerFunc
 	index    int
+	// values stored with Set, mu guards Keys for handlers that start goroutines
+	mu   sync.RWMutex
+	Keys map[string]interface{}
+	// the parsed query and post form, see query.go
+	queryCache url.Values
+	formCache  url.Values
+	// errors attached with Error, for the middlewares to log or report
+	Errors Errors
 	// engine pointer
 	engine *Engine
 }
 
-// NewContext is the constructor of Context
-func newContext(w http.ResponseWriter, r *http.Request) *Context {
-	return &Context{
-		Writer: w,
-		Req:    r,
-		Path:   r.URL.Path,
-		Method: r.Method,
-		index:  -1,
+// reset prepares a pooled Context for a new request, the Params slice is kept for reuse
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	c.writermem.reset(w)
+	c.Writer = &c.writermem
+	c.Req = r
+	c.Path = r.URL.Path
+	c.Method = r.Method
+	c.Params = c.Params[:0]
+	c.StatusCode = 0
+	c.handlers = nil
+	c.index = -1
+	c.Errors = c.Errors[:0]
+	c.Keys = nil
+	c.queryCache = nil
+	c.formCache = nil
+}
+
+// Copy returns a copy of the Context for goroutines that outlive the request, its Params, Keys and
+// Req do not change when the Context is recycled. The copy has no handlers and its Writer must not be used.
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		writermem:  c.writermem,
+		Req:        c.Req.Clone(c.Req.Context()),
+		Path:       c.Path,
+		Method:     c.Method,
+		Params:     slices.Clone(c.Params),
+		StatusCode: c.StatusCode,
+		index:      abortIndex,
+		Errors:     slices.Clone(c.Errors),
+		engine:     c.engine,
+	}
+	cp.writermem.ResponseWriter = nil
+	cp.Writer = &cp.writermem
+
+	c.mu.RLock()
+	cp.Keys = maps.Clone(c.Keys)
+	c.mu.RUnlock()
+
+	return cp
+}
+
+// Context implements context.Context so it can be passed to database and http clients directly,
+// see Engine.ContextWithFallback. Like the Context itself it must not be used once the handlers return,
+// pass c.Req.Context() or c.Copy() to goroutines that outlive the request.
+var _ context.Context = (*Context)(nil)
+
+// hasRequestContext reports whether the context.Context methods delegate to the request context
+func (c *Context) hasRequestContext() bool {
+	return c.engine != nil && c.engine.ContextWithFallback && c.Req != nil
+}
+
+// Deadline returns the deadline of the request context
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if !c.hasRequestContext() {
+		return
+	}
+
+	return c.Req.Context().Deadline()
+}
+
+// Done returns the channel closed when the request is canceled, e.g. when the client goes away
+func (c *Context) Done() <-chan struct{} {
+	if !c.hasRequestContext() {
+		return nil
+	}
+
+	return c.Req.Context().Done()
+}
+
+// Err returns why the request context is done, nil while it is not
+func (c *Context) Err() error {
+	if !c.hasRequestContext() {
+		return nil
+	}
+
+	return c.Req.Context().Err()
+}
+
+// Value returns the value stored with Set for a string key, then the value of the request context
+func (c *Context) Value(key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		c.mu.RLock()
+		value, exists := c.Keys[k]
+		c.mu.RUnlock()
+		if exists {
+			return value
+		}
 	}
+
+	if !c.hasRequestContext() {
+		return nil
+	}
+
+	return c.Req.Context().Value(key)
 }
 
 // Next is used to call the next middleware
@@ -48,27 +178,68 @@ func (c *Context) Next() {
 	}
 }
 
+// Abort stops the pending handlers of the chain, the current one still runs to its end
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted reports whether the chain was aborted
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// AbortWithStatus aborts the chain and sends the headers with code, e.g. 401 from an auth middleware
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Writer.WriteHeaderNow()
+	c.Abort()
+}
+
+// AbortWithStatusJSON aborts the chain and writes obj as the json body
+func (c *Context) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+// AbortWithError aborts the chain with code and attaches err to the Context, see Error
+func (c *Context) AbortWithError(code int, err error) *Error {
+	c.AbortWithStatus(code)
+	return c.Error(err)
+}
+
 // Fail is a helper function that returns the error message and sets the status code
 func (c *Context) Fail(code int, err string) {
-	c.index = len(c.handlers)
-	c.JSON(code, H{"message": err})
+	c.AbortWithStatusJSON(code, H{"message": err})
+}
+
+// Error attaches err to the Context so a middleware can log or report it, err must not be nil
+func (c *Context) Error(err error) *Error {
+	if err == nil {
+		panic("gee: Context.Error called with a nil error")
+	}
+
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Err: err}
+	}
+	c.Errors = append(c.Errors, e)
+
+	return e
 }
 
 // param is a helper function that parse the url parameters
 func (c *Context) Param(key string) string {
-	return c.Params[key]
+	return c.Params.ByName(key)
 }
 
-// PostForm is a helper function that parse form data
-func (c *Context) PostForm(key string) string {
-	// example: curl http://localhost:9999/form  -X POST -d 'username=geektutu&password=1234'
-	return c.Req.FormValue(key)
+// ParamInt returns the url parameter key as an int, e.g. for /users/:id<int>
+func (c *Context) ParamInt(key string) (int, error) {
+	return strconv.Atoi(c.Param(key))
 }
 
-// Query is a helper function that parse query data
-func (c *Context) Query(key string) string {
-	// example: curl http://localhost:9999/?username=geektutu&password=1234
-	return c.Req.URL.Query().Get(key)
+// ParamUUID returns the url parameter key as a UUID, e.g. for /v/:ver{uuid}
+func (c *Context) ParamUUID(key string) (UUID, error) {
+	return ParseUUID(c.Param(key))
 }
 
 // Status sets the status code for the response
@@ -82,40 +253,111 @@ func (c *Context) SetHeader(key, value string) {
 	c.Writer.Header().Set(key, value)
 }
 
+// Render sets the status code and writes the body with r, the other response helpers all use it.
+// Statuses without a body, e.g. 204 and 304, only get the headers. An error of r is attached to
+// the Context and aborts the chain, with a 500 when nothing was sent yet.
+func (c *Context) Render(code int, r Render) {
+	if code > 0 {
+		c.Status(code)
+	}
+
+	if !bodyAllowedForStatus(c.Writer.Status()) {
+		r.WriteContentType(c.Writer)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	if err := r.Render(c.Writer); err != nil {
+		c.Error(err)
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+}
+
+// bodyAllowedForStatus reports whether a response with status may have a body
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+
+	return true
+}
+
 // String sets the string data for the response
 func (c *Context) String(code int, format string, values ...interface{}) {
-	c.SetHeader("Content-Type", "text/plain")
-	c.Status(code)
-
-	c.Writer.Write([]byte(fmt.Sprintf(format, values...)))
+	c.Render(code, Text{Format: format, Data: values})
 }
 
 // JSON sets the json data for the response
 func (c *Context) JSON(code int, obj interface{}) {
-	c.SetHeader("Content-Type", "application/json")
-	c.Status(code)
-
 	// obj is the gee.H type
-	encoder := json.NewEncoder(c.Writer)
-	if err := encoder.Encode(obj); err != nil {
-		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
-	}
+	c.Render(code, JSON{Data: obj})
+}
+
+// IndentedJSON sets the json data indented for humans, it is larger and slower than JSON
+func (c *Context) IndentedJSON(code int, obj interface{}) {
+	c.Render(code, IndentedJSON{Data: obj})
+}
+
+// SecureJSON sets the json data, prefixing a top level array with while(1);
+func (c *Context) SecureJSON(code int, obj interface{}) {
+	c.Render(code, SecureJSON{Prefix: defaultSecureJSONPrefix, Data: obj})
+}
+
+// JSONP sets the json data wrapped in the function named by the callback query, plain json without it
+func (c *Context) JSONP(code int, obj interface{}) {
+	c.Render(code, JSONP{Callback: c.Query("callback"), Data: obj})
+}
+
+// AsciiJSON sets the json data with the non ASCII characters escaped
+func (c *Context) AsciiJSON(code int, obj interface{}) {
+	c.Render(code, AsciiJSON{Data: obj})
+}
+
+// PureJSON sets the json data without escaping the html characters
+func (c *Context) PureJSON(code int, obj interface{}) {
+	c.Render(code, PureJSON{Data: obj})
+}
+
+// XML sets the xml data for the response
+func (c *Context) XML(code int, obj interface{}) {
+	c.Render(code, XML{Data: obj})
+}
+
+// YAML sets the yaml data for the response, see marshalYAML for what is supported
+func (c *Context) YAML(code int, obj interface{}) {
+	c.Render(code, YAML{Data: obj})
+}
+
+// ProtoBuf sets the protobuf data for the response, obj must have a Marshal() ([]byte, error)
+// method as the messages generated by protoc do
+func (c *Context) ProtoBuf(code int, obj interface{}) {
+	c.Render(code, ProtoBuf{Data: obj})
 }
 
 // Data sets the data for the response
 func (c *Context) Data(code int, data []byte) {
-	c.Status(code)
+	c.Render(code, Data{Data: data})
+}
 
-	c.Writer.Write(data)
+// DataFromReader streams reader as the response, e.g. a file from object storage
+func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
+	c.Render(code, Reader{ContentType: contentType, ContentLength: contentLength, Headers: extraHeaders, Reader: reader})
 }
 
 // HTML sets the html data for the response
 func (c *Context) HTML(code int, name string, data interface{}) {
-	c.SetHeader("Content-Type", "text/html")
-	c.Status(code)
-
 	// 渲染模板
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
-		c.Fail(http.StatusInternalServerError, err.Error())
-	}
+	c.Render(code, HTML{Template: c.engine.htmlTemplates, Name: name, Data: data})
+}
+
+// Redirect sends the client to location with code, a 3xx or 201
+func (c *Context) Redirect(code int, location string) {
+	c.Render(code, Redirect{Code: code, Location: location})
 }
@@ -0,0 +1,51 @@
+package gee
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Error is an error attached to a request with Context.Error
+type Error struct {
+	Err  error
+	Meta interface{} // extra data about the error, e.g. the name of the failing field
+}
+
+// SetMeta sets the extra data of the error
+func (e *Error) SetMeta(meta interface{}) *Error {
+	e.Meta = meta
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors is the list of errors attached to a request, in the order they were attached
+type Errors []*Error
+
+// Last returns the last attached error, nil if there is none
+func (errs Errors) Last() *Error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs[len(errs)-1]
+}
+
+// String returns the messages of the errors, one per line
+func (errs Errors) String() string {
+	var str strings.Builder
+	for i, err := range errs {
+		if i > 0 {
+			str.WriteString("\n")
+		}
+		str.WriteString("Error #" + strconv.Itoa(i+1) + ": " + err.Error())
+	}
+
+	return str.String()
+}
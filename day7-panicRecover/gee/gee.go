@@ -4,17 +4,25 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 )
 
 // HandlerFunc defines the request handler used by gee
 type HandlerFunc func(*Context)
 
+// anyMethods are the methods registered by RouterGroup.Any
+var anyMethods = []string{
+	"GET", "POST", "PUT", "PATCH", "HEAD", "OPTIONS", "DELETE", "CONNECT", "TRACE",
+}
+
 type RouterGroup struct {
 	prefix      string        // gorup prefix
 	middlewares []HandlerFunc // support middleware
 	parent      *RouterGroup  // support nesting
 	engine      *Engine       // all groups share a Engine instance
+	router      *router       // the engine router, or the router of a Host group
 }
 
 // Group is defined to create a new RouterGroup
@@ -26,8 +34,8 @@ func (group *RouterGroup) Group(prefix string) *RouterGroup {
 		middlewares: nil,
 		parent:      group,
 		engine:      engine,
+		router:      group.router,
 	}
-	engine.groups = append(engine.groups, newGroup)
 
 	return newGroup
 }
@@ -67,42 +75,263 @@ func (group *RouterGroup) Static(relativePath string, root string) {
 	group.GET(urlPattern, handler)
 }
 
+/*
+Mount is defined to serve every request under prefix with an http.Handler,
+which sees the path with the group prefix and prefix stripped
+
+example: group.Mount("/debug/pprof", mux) with group prefix /admin
+
+the url will be: /admin/debug/pprof, /admin/debug/pprof/ and /admin/debug/pprof/*mountpath
+
+/admin/debug/pprof/heap is served by mux as /heap
+*/
+func (group *RouterGroup) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	segments := strings.Count(cleanPath(group.prefix+prefix), "/")
+	if group.prefix+prefix == "" {
+		segments = 0
+	}
+
+	handler := func(c *Context) {
+		// strip the escaped path so escapes in the rest survive, e.g. /a%2Fb,
+		// cleaned like the path the router matched
+		escaped := c.Req.URL.EscapedPath()
+		if c.engine.RemoveExtraSlash {
+			escaped = cleanPath(escaped)
+		}
+		rest := stripSegments(escaped, segments)
+		p, err := url.PathUnescape(rest)
+		if err != nil {
+			p = rest
+		}
+
+		req := new(http.Request)
+		*req = *c.Req
+		req.URL = new(url.URL)
+		*req.URL = *c.Req.URL
+		req.URL.Path = p
+		req.URL.RawPath = rest
+
+		h.ServeHTTP(c.Writer, req)
+	}
+
+	group.Any(prefix+"/*mountpath", handler)
+	group.Any(prefix+"/", handler)
+	if prefix != "" {
+		group.Any(prefix, handler)
+	}
+}
+
+// stripSegments removes the first n segments of p, example: (/debug/pprof/heap, 2) => /heap
+func stripSegments(p string, n int) string {
+	for ; n > 0; n-- {
+		i := strings.IndexByte(p[min(1, len(p)):], '/')
+		if i == -1 {
+			return "/"
+		}
+		p = p[i+1:]
+	}
+
+	return p
+}
+
 // Use is defined to add middleware to the group
+// the routes already registered on the group and its subgroups get the middlewares too
 func (group *RouterGroup) Use(middlewares ...HandlerFunc) {
 	group.middlewares = append(group.middlewares, middlewares...)
+	group.engine.rebuildHandlers()
 }
 
-// addRoute adds a route with a method and a pattern to the Engine instance
-func (group *RouterGroup) addRoute(method string, comp string, handler HandlerFunc) {
+// combineHandlers returns the middlewares of the root group down to this group, followed by handlers
+func (group *RouterGroup) combineHandlers(handlers []HandlerFunc) []HandlerFunc {
+	size := len(handlers)
+	for g := group; g != nil; g = g.parent {
+		size += len(g.middlewares)
+	}
+
+	merged := make([]HandlerFunc, size)
+	end := size - len(handlers)
+	copy(merged[end:], handlers)
+
+	for g := group; g != nil; g = g.parent {
+		end -= len(g.middlewares)
+		copy(merged[end:], g.middlewares)
+	}
+
+	return merged
+}
+
+// addRoute adds a route with a method and a pattern to the Engine instance,
+// it panics if the pattern is invalid or conflicts with an existing route
+func (group *RouterGroup) addRoute(method string, comp string, handlers ...HandlerFunc) *Route {
+	route, err := group.TryHandle(method, comp, handlers...)
+	if err != nil {
+		panic(err)
+	}
+
+	return route
+}
+
+// TryHandle is the non-panicking variant of Handle, it returns a *RouteError naming both
+// patterns when the route conflicts with an existing one, and registers nothing in that case.
+// Handlers run in order after the group middlewares, e.g. GET("/admin", auth, handler).
+// The middlewares of the group and its parents are resolved here and stored with the route,
+// Use rebuilds the stored chains when it adds middlewares later.
+func (group *RouterGroup) TryHandle(method string, comp string, handlers ...HandlerFunc) (*Route, error) {
 	pattern := group.prefix + comp
+
+	if len(handlers) == 0 {
+		return nil, &RouteError{Method: method, Pattern: pattern, Reason: "no handlers"}
+	}
+
+	route := &Route{
+		method:   method,
+		pattern:  pattern,
+		group:    group,
+		own:      handlers,
+		handlers: group.combineHandlers(handlers),
+	}
+
+	if err := group.router.addRoute(method, pattern, route.handlers...); err != nil {
+		return nil, err
+	}
+
+	group.engine.routes = append(group.engine.routes, route)
+	if params := countParams(pattern) + strings.Count("."+group.router.host, ".:"); params > group.engine.maxParams {
+		group.engine.maxParams = params
+	}
 	log.Printf("Route %4s - %s", method, pattern)
-	group.engine.router.addRoute(method, pattern, handler)
+
+	return route, nil
+}
+
+// NoAutoHEAD stops HEAD requests from being answered by the GET handler of pattern
+func (group *RouterGroup) NoAutoHEAD(pattern string) {
+	group.router.noAuto["HEAD-"+group.prefix+pattern] = true
+}
+
+// NoAutoOPTIONS stops OPTIONS requests to pattern from being answered automatically
+func (group *RouterGroup) NoAutoOPTIONS(pattern string) {
+	group.router.noAuto["OPTIONS-"+group.prefix+pattern] = true
 }
 
 // group GET method
-func (group *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	group.addRoute("GET", pattern, handler)
+func (group *RouterGroup) GET(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("GET", pattern, handlers...)
 }
 
 // group POST method
-func (group *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	group.addRoute("POST", pattern, handler)
+func (group *RouterGroup) POST(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("POST", pattern, handlers...)
+}
+
+// group PUT method
+func (group *RouterGroup) PUT(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("PUT", pattern, handlers...)
+}
+
+// group PATCH method
+func (group *RouterGroup) PATCH(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("PATCH", pattern, handlers...)
+}
+
+// group DELETE method
+func (group *RouterGroup) DELETE(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("DELETE", pattern, handlers...)
+}
+
+// group HEAD method
+func (group *RouterGroup) HEAD(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("HEAD", pattern, handlers...)
+}
+
+// group OPTIONS method
+func (group *RouterGroup) OPTIONS(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("OPTIONS", pattern, handlers...)
+}
+
+// Handle registers handlers with the given method, e.g. group.Handle("LINK", "/p/:lang", handler)
+func (group *RouterGroup) Handle(method string, pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(method, pattern, handlers...)
+}
+
+// Any registers handlers on every method in anyMethods
+func (group *RouterGroup) Any(pattern string, handlers ...HandlerFunc) {
+	for _, method := range anyMethods {
+		group.addRoute(method, pattern, handlers...)
+	}
 }
 
 // Engine implement the interface of ServeHTTP
 type Engine struct {
 	*RouterGroup
 	router        *router
-	groups        []*RouterGroup     // store all groups
 	htmlTemplates *template.Template // for html render
 	funcMap       template.FuncMap   // for html render
+	noRoute       []HandlerFunc      // run when no route matches
+	noMethod      []HandlerFunc      // run when the path only matches other methods
+	allNoRoute    []HandlerFunc      // the engine middlewares followed by noRoute
+	allNoMethod   []HandlerFunc      // the engine middlewares followed by noMethod
+	routes        []*Route           // all routes in registration order
+	namedRoutes   map[string]*Route  // for URL
+	pool          sync.Pool          // recycles Contexts between requests
+	maxParams     int                // the most params of a route, to size Context.Params
+	hosts         map[string]*router // routers of the exact Host groups, key eg, api.example.com
+	wildHosts     []*router          // routers of the wildcard Host groups, in registration order
+
+	// HandleMethodNotAllowed replies 405 with an Allow header when the path
+	// is only registered under other methods, instead of 404
+	HandleMethodNotAllowed bool
+	// HandleHEAD answers HEAD with the GET handler of the path, discarding the body
+	HandleHEAD bool
+	// HandleOPTIONS answers OPTIONS with the Allow header computed from the registered routes
+	HandleOPTIONS bool
+	// RedirectTrailingSlash redirects /hello/ to /hello when only the latter is registered, and vice versa
+	RedirectTrailingSlash bool
+	// RedirectFixedPath redirects to the registered path after removing duplicate slashes,
+	// resolving . and .. and matching the static parts ignoring case, e.g. /a//../HELLO => /hello
+	RedirectFixedPath bool
+	// RemoveExtraSlash routes /a//hello as /a/hello without redirecting
+	RemoveExtraSlash bool
+	// UseRawPath routes on the escaped path, so /files/a%2Fb matches /files/:name with name a/b
+	// instead of being split into two segments. Patterns must then use the escaped form of
+	// characters outside the URL path charset.
+	UseRawPath bool
+	// UnescapePathValues unescapes the params matched when UseRawPath is set
+	UnescapePathValues bool
+	// ContextWithFallback makes Deadline, Done, Err and Value of Context delegate to the request
+	// context when the Context is used as a context.Context, otherwise it never expires and Value
+	// only sees the keys stored with Set
+	ContextWithFallback bool
+	// MaxMultipartMemory is how many bytes of the file parts of a multipart body are kept in memory,
+	// larger parts are streamed to temp files
+	MaxMultipartMemory int64
 }
 
+// defaultMultipartMemory is the default Engine.MaxMultipartMemory, as in net/http
+const defaultMultipartMemory = 32 << 20
+
 // New is the constructor of gee.Engine
 func New() *Engine {
-	engine := &Engine{router: newRouter()}
-	engine.RouterGroup = &RouterGroup{engine: engine}
-	engine.groups = []*RouterGroup{engine.RouterGroup}
+	engine := &Engine{
+		router:                 newRouter(),
+		noRoute:                []HandlerFunc{notFound},
+		noMethod:               []HandlerFunc{methodNotAllowed},
+		namedRoutes:            make(map[string]*Route),
+		hosts:                  make(map[string]*router),
+		HandleMethodNotAllowed: true,
+		HandleHEAD:             true,
+		HandleOPTIONS:          true,
+		RedirectTrailingSlash:  true,
+		UnescapePathValues:     true,
+		ContextWithFallback:    true,
+		MaxMultipartMemory:     defaultMultipartMemory,
+	}
+	engine.RouterGroup = &RouterGroup{engine: engine, router: engine.router}
+	engine.rebuildHandlers()
+	engine.pool.New = func() interface{} {
+		return &Context{engine: engine, Params: make(Params, 0, engine.maxParams)}
+	}
 
 	return engine
 }
@@ -113,12 +342,64 @@ func Defualt() *Engine {
 	return engine
 }
 
+// NoRoute sets the handlers run after the middlewares when no route matches,
+// replacing the default 404 response
+func (e *Engine) NoRoute(handlers ...HandlerFunc) {
+	e.noRoute = handlers
+	e.allNoRoute = e.combineHandlers(e.noRoute)
+}
+
+// NoMethod sets the handlers run after the middlewares when the path is only
+// registered under other methods, replacing the default 405 response.
+// The Allow header is set before they run.
+func (e *Engine) NoMethod(handlers ...HandlerFunc) {
+	e.noMethod = handlers
+	e.allNoMethod = e.combineHandlers(e.noMethod)
+}
+
+// rebuildHandlers resolves the middlewares of every route and of the NoRoute and NoMethod handlers
+// again, so a middleware added by Use after the routes applies to them all
+func (e *Engine) rebuildHandlers() {
+	type routeKey struct {
+		router  *router
+		method  string
+		pattern string
+	}
+
+	chains := make(map[routeKey][]HandlerFunc, len(e.routes))
+	for _, route := range e.routes {
+		route.handlers = route.group.combineHandlers(route.own)
+		chains[routeKey{route.group.router, route.method, route.pattern}] = route.handlers
+	}
+
+	// the nodes holding the chains move when the trie is split, so they are found again by pattern
+	routers := append([]*router{e.router}, e.wildHosts...)
+	for _, r := range e.hosts {
+		routers = append(routers, r)
+	}
+	for _, r := range routers {
+		for method, root := range r.roots {
+			root.walk(func(n *node) {
+				if n.pattern != "" {
+					n.handlers = chains[routeKey{r, method, n.pattern}]
+				}
+			})
+		}
+	}
+
+	e.allNoRoute = e.combineHandlers(e.noRoute)
+	e.allNoMethod = e.combineHandlers(e.noMethod)
+}
+
 func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
 	e.funcMap = funcMap
 }
 
+// LoadHTMLGlob loads the templates, they can call {{url "name" "key" "value"}} to build
+// the URL of a named route, see Engine.URL
 func (e *Engine) LoadHTMLGlob(pattern string) {
-	e.htmlTemplates = template.Must(template.New("").Funcs(e.funcMap).ParseGlob(pattern))
+	funcs := template.FuncMap{"url": e.URL}
+	e.htmlTemplates = template.Must(template.New("").Funcs(funcs).Funcs(e.funcMap).ParseGlob(pattern))
 }
 
 // Run defines the method to start a http server
@@ -128,15 +409,20 @@ func (e *Engine) Run(addr string) (err error) {
 }
 
 // ServeHTTP defines the method to serve HTTP request
+// the Context is recycled once the handlers return, so goroutines they start must use c.Copy()
 func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var middlewares []HandlerFunc
-	for _, group := range e.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
-		}
+	c := e.pool.Get().(*Context)
+	c.reset(w, req)
+
+	var r *router
+	r, c.Params = e.routerFor(req.Host, c.Params)
+	r.handle(c)
+	// a status set without a body, e.g. c.Status(204), is only sent now
+	c.writermem.WriteHeaderNow()
+	// the temp files of the uploads, a Server also removes them but only for its own request
+	if c.Req.MultipartForm != nil {
+		c.Req.MultipartForm.RemoveAll()
 	}
-	c := newContext(w, req)
-	c.handlers = middlewares
-	c.engine = e
-	e.router.handle(c)
+
+	e.pool.Put(c)
 }
@@ -0,0 +1,1011 @@
+package gee
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouterGroupMethods(t *testing.T) {
+	r := New()
+	v1 := r.Group("/v1")
+
+	handler := func(c *Context) {
+		c.String(http.StatusOK, "%s", c.Method)
+	}
+
+	v1.PUT("/p", handler)
+	v1.PATCH("/p", handler)
+	v1.DELETE("/p", handler)
+	v1.Handle("LINK", "/p", handler)
+	v1.Any("/any", handler)
+
+	methods := []string{"PUT", "PATCH", "DELETE", "LINK"}
+	for _, method := range methods {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/v1/p", nil))
+
+		if w.Code != http.StatusOK || w.Body.String() != method {
+			t.Fatalf("%s /v1/p: expect 200 %s, but got %d %s", method, method, w.Code, w.Body.String())
+		}
+	}
+
+	for _, method := range anyMethods {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/v1/any", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s /v1/any: expect 200, but got %d", method, w.Code)
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.GET("/p/:lang", func(c *Context) {})
+	r.PUT("/p/:lang", func(c *Context) {})
+	r.POST("/p/book", func(c *Context) {})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/p/go", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expect 405, but got %d", w.Code)
+	}
+
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, PUT" {
+		t.Fatalf("expect Allow: GET, HEAD, OPTIONS, PUT, but got %q", allow)
+	}
+
+	r.HandleMethodNotAllowed = false
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/p/go", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404, but got %d", w.Code)
+	}
+}
+
+func TestAutoHEADAndOPTIONS(t *testing.T) {
+	r := New()
+	r.GET("/p/:lang", func(c *Context) {
+		c.String(http.StatusOK, "hello %s", c.Param("lang"))
+	})
+	r.POST("/p/:lang", func(c *Context) {})
+	r.GET("/private", func(c *Context) {})
+	r.NoAutoHEAD("/private")
+	r.NoAutoOPTIONS("/private")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("HEAD", "/p/go", nil))
+
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Fatalf("HEAD /p/go: expect 200 without body, but got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/p/go", nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("OPTIONS /p/go: expect 204, but got %d", w.Code)
+	}
+
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, POST" {
+		t.Fatalf("OPTIONS /p/go: expect Allow: GET, HEAD, OPTIONS, POST, but got %q", allow)
+	}
+
+	for _, method := range []string{"HEAD", "OPTIONS"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/private", nil))
+
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET" {
+			t.Fatalf("%s /private: expect 405 with Allow: GET, but got %d %q", method, w.Code, w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestNoRouteAndNoMethod(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.SetHeader("X-Middleware", "1")
+		c.Next()
+	})
+	r.NoRoute(func(c *Context) {
+		c.JSON(http.StatusNotFound, H{"message": "no route"})
+	})
+	r.NoMethod(func(c *Context) {
+		c.JSON(http.StatusMethodNotAllowed, H{"message": "no method"})
+	})
+	r.GET("/hello", func(c *Context) {})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+
+	if w.Code != http.StatusNotFound || w.Body.String() != "{\"message\":\"no route\"}\n" {
+		t.Fatalf("expect 404 no route, but got %d %s", w.Code, w.Body.String())
+	}
+
+	if w.Header().Get("X-Middleware") != "1" {
+		t.Fatal("middleware should run before NoRoute handlers")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/hello", nil))
+
+	if w.Code != http.StatusMethodNotAllowed || w.Body.String() != "{\"message\":\"no method\"}\n" {
+		t.Fatalf("expect 405 no method, but got %d %s", w.Code, w.Body.String())
+	}
+
+	if w.Header().Get("Allow") == "" {
+		t.Fatal("Allow header should be set before NoMethod handlers")
+	}
+}
+
+func TestRouteHandlerChain(t *testing.T) {
+	r := New()
+
+	var order []string
+	record := func(name string) HandlerFunc {
+		return func(c *Context) {
+			order = append(order, name)
+			c.Next()
+		}
+	}
+
+	r.Use(record("group"))
+	r.GET("/admin", record("auth"), record("validate"), func(c *Context) {
+		order = append(order, "handler")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/admin", nil))
+
+	if got := strings.Join(order, ","); got != "group,auth,validate,handler" {
+		t.Fatalf("expect group,auth,validate,handler, but got %s", got)
+	}
+}
+
+func TestGroupMiddlewaresResolvedFromRoute(t *testing.T) {
+	r := New()
+
+	var called []string
+	v1 := r.Group("/v1")
+	v1.Use(func(c *Context) {
+		called = append(called, "v1")
+		c.Next()
+	})
+	admin := v1.Group("/admin")
+	admin.Use(func(c *Context) {
+		called = append(called, "admin")
+		c.Next()
+	})
+
+	v1.GET("/hello", func(c *Context) {})
+	admin.GET("/users", func(c *Context) {})
+	r.GET("/v10/hello", func(c *Context) {})
+
+	tests := map[string]string{
+		"/v1/hello":       "v1",
+		"/v1/admin/users": "v1,admin",
+		"/v10/hello":      "",
+	}
+
+	for path, expect := range tests {
+		called = nil
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+
+		if got := strings.Join(called, ","); got != expect {
+			t.Fatalf("%s: expect middlewares %q, but got %q", path, expect, got)
+		}
+	}
+}
+
+func TestUseAfterRoutes(t *testing.T) {
+	r := New()
+	v1 := r.Group("/v1")
+	r.GET("/x", func(c *Context) {})
+	v1.GET("/y", func(c *Context) {})
+
+	r.Use(func(c *Context) {
+		c.SetHeader("X-Engine", "1")
+		c.Next()
+	})
+	v1.Use(func(c *Context) {
+		c.SetHeader("X-V1", "1")
+		c.Next()
+	})
+
+	tests := map[string]string{
+		"/x":       "1,",
+		"/v1/y":    "1,1",
+		"/missing": "1,",
+	}
+
+	for path, expect := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		if got := w.Header().Get("X-Engine") + "," + w.Header().Get("X-V1"); got != expect {
+			t.Fatalf("%s: expect the middlewares added after the routes to run (%s), but got %s", path, expect, got)
+		}
+	}
+}
+
+func TestTryHandle(t *testing.T) {
+	r := New()
+	r.GET("/p/:lang/x", func(c *Context) {})
+
+	_, err := r.TryHandle("GET", "/p/:name/y", func(c *Context) {})
+	if err == nil || !strings.Contains(err.Error(), "/p/:name/y") || !strings.Contains(err.Error(), "/p/:lang/x") {
+		t.Fatalf("expect an error naming both patterns, but got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("GET should panic on a conflicting route")
+		}
+	}()
+	r.GET("/p/:name/y", func(c *Context) {})
+}
+
+func TestTypedParams(t *testing.T) {
+	r := New()
+	r.GET("/users/:id<int>", func(c *Context) {
+		id, err := c.ParamInt("id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.String(http.StatusOK, "int %d", id)
+	})
+	r.GET("/v/:ver{uuid}", func(c *Context) {
+		ver, err := c.ParamUUID("ver")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.String(http.StatusOK, "uuid %s", ver)
+	})
+
+	tests := map[string]string{
+		"/users/42": "int 42",
+		"/v/0F8FAD5B-D9CB-469F-A165-70867728950E": "uuid 0f8fad5b-d9cb-469f-a165-70867728950e",
+	}
+
+	for path, expect := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		if w.Body.String() != expect {
+			t.Fatalf("%s: expect %q, but got %q", path, expect, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/users/geektutu", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404 for a non-int id, but got %d", w.Code)
+	}
+}
+
+func TestURL(t *testing.T) {
+	r := New()
+	r.GET("/hello/:name", func(c *Context) {}).Name("hello")
+	r.GET("/users/:id<int>/", func(c *Context) {}).Name("user")
+	r.Group("/assets").GET("/*filepath", func(c *Context) {}).Name("assets")
+
+	tests := []struct {
+		name   string
+		params []string
+		expect string
+	}{
+		{"hello", []string{"name", "geek tutu"}, "/hello/geek%20tutu"},
+		{"hello", []string{"name", "a/b"}, "/hello/a%2Fb"},
+		{"user", []string{"id", "42"}, "/users/42/"},
+		{"assets", []string{"filepath", "css/geek tutu.css"}, "/assets/css/geek%20tutu.css"},
+		{"assets", []string{"filepath", "/css/a.css"}, "/assets/css/a.css"},
+	}
+
+	for _, test := range tests {
+		url, err := r.URL(test.name, test.params...)
+		if err != nil || url != test.expect {
+			t.Fatalf("%s %v: expect %s, but got %s %v", test.name, test.params, test.expect, url, err)
+		}
+	}
+
+	errTests := [][]string{
+		{"missing"},
+		{"hello"},
+		{"hello", "name"},
+		{"hello", "name", "go", "lang", "go"},
+		{"user", "id", "geektutu"},
+	}
+
+	for _, test := range errTests {
+		if _, err := r.URL(test[0], test[1:]...); err == nil {
+			t.Fatalf("%v: expect an error", test)
+		}
+	}
+
+	dir := t.TempDir()
+	link := `<a href="{{url "hello" "name" .}}">{{.}}</a>`
+	if err := os.WriteFile(filepath.Join(dir, "link.html"), []byte(link), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r.LoadHTMLGlob(filepath.Join(dir, "*"))
+	r.GET("/link", func(c *Context) {
+		c.HTML(http.StatusOK, "link.html", "geektutu")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/link", nil))
+
+	if expect := `<a href="/hello/geektutu">geektutu</a>`; w.Body.String() != expect {
+		t.Fatalf("expect %s, but got %s", expect, w.Body.String())
+	}
+}
+
+func helloHandler(c *Context) {}
+
+func TestRoutes(t *testing.T) {
+	r := New()
+	r.Use(Logger())
+	v1 := r.Group("/v1")
+	v1.POST("/hello", helloHandler)
+	v1.GET("/hello", Recovery(), helloHandler).Name("hello")
+
+	routes := r.Routes()
+	if len(routes) != 2 {
+		t.Fatalf("expect 2 routes, but got %d", len(routes))
+	}
+
+	route := routes[0]
+	if route.Method != "GET" || route.Pattern != "/v1/hello" || route.Name != "hello" || route.Group != "/v1" {
+		t.Fatalf("unexpected route %+v", route)
+	}
+
+	if !strings.HasSuffix(route.Handler, "gee.helloHandler") {
+		t.Fatalf("expect handler gee.helloHandler, but got %s", route.Handler)
+	}
+
+	if len(route.Middlewares) != 2 || !strings.Contains(route.Middlewares[0], "gee.Logger") ||
+		!strings.Contains(route.Middlewares[1], "gee.Recovery") {
+		t.Fatalf("expect Logger and Recovery middlewares, but got %v", route.Middlewares)
+	}
+
+	var table strings.Builder
+	if err := r.PrintRoutes(&table); err != nil || !strings.Contains(table.String(), "/v1/hello") {
+		t.Fatalf("unexpected route table %q %v", table.String(), err)
+	}
+
+	data, err := r.RoutesJSON()
+	if err != nil || !strings.Contains(string(data), `"pattern": "/v1/hello"`) {
+		t.Fatalf("unexpected route json %s %v", data, err)
+	}
+}
+
+func TestRedirectFixedPath(t *testing.T) {
+	r := New()
+	r.GET("/hello", func(c *Context) {})
+	r.POST("/users/", func(c *Context) {})
+	r.GET("/p/:lang/doc", func(c *Context) {})
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{"GET", "/hello/?a=1", http.StatusMovedPermanently, "/hello?a=1"},
+		{"POST", "/users", http.StatusPermanentRedirect, "/users/"},
+		{"GET", "/HELLO", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+		if w.Code != test.code || w.Header().Get("Location") != test.location {
+			t.Fatalf("%s %s: expect %d %q, but got %d %q", test.method, test.path, test.code, test.location,
+				w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	r.RedirectFixedPath = true
+	tests = []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{"GET", "/HELLO", http.StatusMovedPermanently, "/hello"},
+		{"GET", "/a/..//Hello/", http.StatusMovedPermanently, "/hello"},
+		{"GET", "/P/Go/DOC", http.StatusMovedPermanently, "/p/Go/doc"},
+		{"POST", "/USERS", http.StatusPermanentRedirect, "/users/"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+		if w.Code != test.code || w.Header().Get("Location") != test.location {
+			t.Fatalf("%s %s: expect %d %q, but got %d %q", test.method, test.path, test.code, test.location,
+				w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	r.RemoveExtraSlash = true
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/p/go//doc", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200 for /p/go//doc, but got %d", w.Code)
+	}
+}
+
+func TestUseRawPath(t *testing.T) {
+	r := New()
+	r.GET("/files/:name", func(c *Context) {
+		c.String(http.StatusOK, "%s", c.Param("name"))
+	})
+	r.GET("/assets/*filepath", func(c *Context) {
+		c.String(http.StatusOK, "%s", c.Param("filepath"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/files/a%2Fb", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404 without UseRawPath, but got %d", w.Code)
+	}
+
+	r.UseRawPath = true
+	tests := map[string]string{
+		"/files/a%2Fb":          "a/b",
+		"/files/a%20b":          "a b",
+		"/assets/css/a%2Fb.css": "css/a/b.css",
+	}
+
+	for path, expect := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		if w.Code != http.StatusOK || w.Body.String() != expect {
+			t.Fatalf("%s: expect 200 %q, but got %d %q", path, expect, w.Code, w.Body.String())
+		}
+	}
+
+	r.UnescapePathValues = false
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/files/a%2Fb", nil))
+
+	if w.Body.String() != "a%2Fb" {
+		t.Fatalf("expect the escaped value a%%2Fb, but got %q", w.Body.String())
+	}
+}
+
+func TestHost(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "default")
+	})
+	r.Host("api.example.com").GET("/", func(c *Context) {
+		c.String(http.StatusOK, "api")
+	})
+	r.Host("www.example.com:8080").GET("/", func(c *Context) {
+		c.String(http.StatusOK, "www")
+	})
+	r.Host(":tenant.example.com").Group("/v1").GET("/:id", func(c *Context) {
+		c.String(http.StatusOK, "%s %s", c.Param("tenant"), c.Param("id"))
+	})
+
+	tests := []struct {
+		host   string
+		path   string
+		code   int
+		expect string
+	}{
+		{"example.com", "/", http.StatusOK, "default"},
+		{"api.example.com:9999", "/", http.StatusOK, "api"},
+		{"API.example.com", "/", http.StatusOK, "api"},
+		{"www.example.com", "/", http.StatusOK, "www"},
+		{"acme.example.com", "/v1/42", http.StatusOK, "acme 42"},
+		{"acme.example.com", "/", http.StatusNotFound, "404 NOT FOUND: /\n"},
+		{"a.acme.example.com", "/v1/42", http.StatusNotFound, "404 NOT FOUND: /v1/42\n"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		req.Host = test.host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != test.code || w.Body.String() != test.expect {
+			t.Fatalf("%s%s: expect %d %q, but got %d %q", test.host, test.path, test.code, test.expect, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestStatic(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.css"), []byte("body{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New()
+	r.Static("/assets", dir)
+
+	tests := map[string]int{
+		"/assets/a.css": http.StatusOK,
+		"/assets/b.css": http.StatusNotFound,
+		// an empty filepath does not list the directory
+		"/assets/": http.StatusNotFound,
+	}
+
+	for path, code := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		if w.Code != code {
+			t.Fatalf("%s: expect %d, but got %d", path, code, w.Code)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Method + " " + req.URL.Path + " " + req.URL.EscapedPath()))
+	})
+
+	sub := New()
+	sub.GET("/hello/:name", func(c *Context) {
+		c.String(http.StatusOK, "sub %s", c.Param("name"))
+	})
+
+	r := New()
+	admin := r.Group("/admin/:tenant")
+	admin.Mount("/mux", mux)
+	r.Mount("/sub/", sub)
+
+	tests := map[string]string{
+		"/admin/acme/mux":          "GET / /",
+		"/admin/acme/mux/heap":     "GET /heap /heap",
+		"/admin/acme/mux/a%2Fb/c":  "GET /a/b/c /a%2Fb/c",
+		"/sub/hello/geektutu":      "sub geektutu",
+		"/sub/hello/geektutu?x=1":  "sub geektutu",
+		"/admin/acme/mux/?debug=1": "GET / /",
+	}
+
+	for path, expect := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		if w.Body.String() != expect {
+			t.Fatalf("%s: expect %q, but got %q", path, expect, w.Body.String())
+		}
+	}
+
+	// the handler sees the path the router matched on
+	r = New()
+	r.RemoveExtraSlash = true
+	r.Mount("/m", mux)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "//m//a%2Fb", nil))
+
+	if expect := "GET /a/b /a%2Fb"; w.Body.String() != expect {
+		t.Fatalf("//m//a%%2Fb: expect %q, but got %q", expect, w.Body.String())
+	}
+}
+
+func TestWrap(t *testing.T) {
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("X-User", "geektutu")
+			next.ServeHTTP(w, req)
+		})
+	}
+
+	r := New()
+	r.Use(WrapMiddleware(auth))
+	r.GET("/f", WrapF(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("f"))
+	}))
+	r.GET("/h", WrapH(http.NotFoundHandler()))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/f", nil))
+
+	if w.Code != http.StatusUnauthorized || w.Body.Len() != 0 {
+		t.Fatalf("expect 401 without body, but got %d %q", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/f", nil)
+	req.Header.Set("Authorization", "token")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Body.String() != "f" || w.Header().Get("X-User") != "geektutu" {
+		t.Fatalf("expect f with X-User, but got %q %q", w.Body.String(), w.Header().Get("X-User"))
+	}
+
+	req = httptest.NewRequest("GET", "/h", nil)
+	req.Header.Set("Authorization", "token")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404 from the wrapped handler, but got %d", w.Code)
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	var status, size int
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		status, size = c.Writer.Status(), c.Writer.Size()
+	})
+	r.Use(Recovery())
+	r.GET("/raw", func(c *Context) {
+		c.Writer.WriteHeader(http.StatusCreated)
+		c.Writer.Write([]byte("raw"))
+	})
+	r.GET("/partial", func(c *Context) {
+		c.Writer.Write([]byte("partial"))
+		panic("after write")
+	})
+	r.GET("/flush", func(c *Context) {
+		c.Writer.Write([]byte("a"))
+		c.Writer.Flush()
+	})
+	r.GET("/empty", func(c *Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/raw", nil))
+	if w.Code != http.StatusCreated || status != http.StatusCreated || size != 3 {
+		t.Fatalf("expect 201 and 3 bytes, but got %d, %d and %d bytes", w.Code, status, size)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/partial", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" || status != http.StatusOK {
+		t.Fatalf("expect the partial 200 response to be kept, but got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/flush", nil))
+	if !w.Flushed {
+		t.Fatal("expect Flush to reach the underlying writer")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/empty", nil))
+	if w.Code != http.StatusNoContent || status != http.StatusNoContent || size != -1 {
+		t.Fatalf("expect 204 without body, but got %d, %d and %d bytes", w.Code, status, size)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	var ran []string
+	var aborted bool
+	var errs Errors
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		aborted, errs = c.IsAborted(), c.Errors
+	})
+	r.Use(func(c *Context) {
+		switch c.Query("abort") {
+		case "status":
+			c.AbortWithStatus(http.StatusUnauthorized)
+		case "json":
+			c.AbortWithStatusJSON(http.StatusForbidden, H{"error": "forbidden"})
+		case "error":
+			c.AbortWithError(http.StatusBadRequest, errors.New("bad request")).SetMeta("abort")
+		}
+		c.Next()
+		ran = append(ran, "after")
+	})
+	r.GET("/", func(c *Context) {
+		ran = append(ran, "handler")
+		c.String(200, "ok")
+	})
+
+	tests := []struct {
+		query string
+		code  int
+		body  string
+		ran   string
+	}{
+		{"", 200, "ok", "handler,after"},
+		{"status", 401, "", "after"},
+		{"json", 403, "{\"error\":\"forbidden\"}\n", "after"},
+		{"error", 400, "", "after"},
+	}
+
+	for _, tt := range tests {
+		ran = nil
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/?abort="+tt.query, nil))
+
+		if w.Code != tt.code || w.Body.String() != tt.body || strings.Join(ran, ",") != tt.ran {
+			t.Fatalf("%q: expect %d %q running %s, but got %d %q running %v", tt.query, tt.code, tt.body, tt.ran, w.Code, w.Body.String(), ran)
+		}
+
+		if aborted != (tt.query != "") {
+			t.Fatalf("%q: expect IsAborted to be %v", tt.query, tt.query != "")
+		}
+	}
+
+	if len(errs) != 1 || errs.Last().Error() != "bad request" || errs.Last().Meta != "abort" {
+		t.Fatalf("expect the bad request error to be attached, but got %v", errs)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	r := New()
+	r.Use(WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := context.WithValue(req.Context(), "tenant", "acme")
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}))
+	r.Use(func(c *Context) {
+		c.Set("user", "geektutu")
+		c.Set("id", 42)
+		c.Next()
+	})
+	r.GET("/", func(c *Context) {
+		if c.GetString("user") != "geektutu" || c.GetInt("id") != 42 || c.GetBool("id") {
+			t.Fatalf("expect the typed getters to return the stored values, but got %v", c.Keys)
+		}
+
+		if id, ok := GetAs[int](c, "id"); !ok || id != 42 {
+			t.Fatalf("expect GetAs to return 42, but got %d %v", id, ok)
+		}
+
+		if _, ok := GetAs[string](c, "id"); ok {
+			t.Fatal("expect GetAs to fail on the wrong type")
+		}
+
+		if c.MustGet("tenant") != "acme" {
+			t.Fatal("expect the request context value to be found")
+		}
+
+		if _, ok := c.Get("missing"); ok {
+			t.Fatal("expect missing to not exist")
+		}
+		c.Status(200)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != 200 {
+		t.Fatalf("expect 200, but got %d", w.Code)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	var cp *Context
+
+	r := New()
+	r.GET("/users/:id", func(c *Context) {
+		c.Set("user", c.Param("id"))
+		if cp == nil {
+			cp = c.Copy()
+		}
+		c.Set("user", "changed")
+	})
+
+	for _, path := range []string{"/users/1", "/users/2"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	}
+
+	// the pooled Context served /users/2 after the copy was made
+	if cp.Param("id") != "1" || cp.GetString("user") != "1" || cp.Req.URL.Path != "/users/1" {
+		t.Fatalf("expect the copy to keep /users/1, but got %v %v %s", cp.Params, cp.Keys, cp.Req.URL.Path)
+	}
+
+	cp.Next()
+	if !cp.IsAborted() {
+		t.Fatal("expect the copy to have no handlers to run")
+	}
+}
+
+func TestContextAsContext(t *testing.T) {
+	type traceKey struct{}
+
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.Set("user", "geektutu")
+
+		var ctx context.Context = c
+		if ctx.Value("user") != "geektutu" || ctx.Value(traceKey{}) != "trace" {
+			t.Fatalf("expect the stored and request values, but got %v and %v", ctx.Value("user"), ctx.Value(traceKey{}))
+		}
+
+		deadline, ok := ctx.Deadline()
+		if r.ContextWithFallback != ok {
+			t.Fatalf("expect the deadline to be delegated when ContextWithFallback is %v", r.ContextWithFallback)
+		}
+		if ok && time.Until(deadline) <= 0 {
+			t.Fatalf("expect a deadline in the future, but got %v", deadline)
+		}
+
+		<-ctx.Done()
+		if ctx.Err() != context.Canceled {
+			t.Fatalf("expect the request to be canceled, but got %v", ctx.Err())
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), traceKey{}, "trace"), time.Hour)
+	cancel()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+	r.ContextWithFallback = false
+	r.GET("/nofallback", func(c *Context) {
+		if c.Done() != nil || c.Err() != nil || c.Value(traceKey{}) != nil {
+			t.Fatal("expect the request context to be ignored without ContextWithFallback")
+		}
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nofallback", nil).WithContext(ctx))
+}
+
+func TestQueryAndPostForm(t *testing.T) {
+	req := httptest.NewRequest("POST", "/?name=&tag=go&tag=web&filter[status]=open&filter[owner]=me&page=2&debug=x&since=1h30m&at=2000-01-02T03:04:05Z",
+		strings.NewReader("user=geektutu&role=admin&role=dev&meta[lang]=go"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c := &Context{Req: req}
+
+	if value, ok := c.GetQuery("name"); !ok || value != "" {
+		t.Fatal("expect name to exist and be empty")
+	}
+	if _, ok := c.GetQuery("missing"); ok || c.DefaultQuery("missing", "def") != "def" || c.DefaultQuery("name", "def") != "" {
+		t.Fatal("expect missing to fall back to its default, and name not to")
+	}
+	if tags := c.QueryArray("tag"); strings.Join(tags, ",") != "go,web" {
+		t.Fatalf("expect go,web, but got %v", tags)
+	}
+	if filter := c.QueryMap("filter"); len(filter) != 2 || filter["status"] != "open" || filter["owner"] != "me" {
+		t.Fatalf("expect the filter map, but got %v", filter)
+	}
+
+	if c.PostForm("user") != "geektutu" || c.PostForm("tag") != "go" || c.DefaultPostForm("missing", "def") != "def" {
+		t.Fatal("expect PostForm to read the body, then the query")
+	}
+	if _, ok := c.GetPostForm("tag"); ok || c.DefaultPostForm("tag", "def") != "def" {
+		t.Fatal("expect GetPostForm and DefaultPostForm to only read the body")
+	}
+	if roles := c.PostFormArray("role"); strings.Join(roles, ",") != "admin,dev" {
+		t.Fatalf("expect admin,dev, but got %v", roles)
+	}
+	if meta := c.PostFormMap("meta"); meta["lang"] != "go" {
+		t.Fatalf("expect the meta map, but got %v", meta)
+	}
+
+	if page, err := c.QueryInt("page"); page != 2 || err != nil {
+		t.Fatalf("expect page 2, but got %d %v", page, err)
+	}
+	if since, err := c.QueryDuration("since"); since != 90*time.Minute || err != nil {
+		t.Fatalf("expect 1h30m, but got %v %v", since, err)
+	}
+	if at, err := c.QueryTime("at", ""); at.Year() != 2000 || err != nil {
+		t.Fatalf("expect year 2000, but got %v %v", at, err)
+	}
+	if n, err := c.QueryInt("missing"); n != 0 || err != nil {
+		t.Fatalf("expect a missing key to give 0 without error, but got %d %v", n, err)
+	}
+	if _, err := c.QueryBool("debug"); err == nil || c.Errors.Last() == nil || c.Errors.Last().Meta != "debug" {
+		t.Fatalf("expect the debug error to be attached, but got %v", c.Errors)
+	}
+}
+
+// pushRecorder is a ResponseRecorder supporting HTTP/2 push
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (w *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	w.pushed = append(w.pushed, target)
+	return nil
+}
+
+func TestPush(t *testing.T) {
+	r := New()
+	r.GET("/", WrapH(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p, ok := w.(http.Pusher)
+		if !ok {
+			t.Fatal("expect the writer passed to net/http handlers to be an http.Pusher")
+		}
+		if err := p.Push("/app.css", nil); err != nil {
+			w.Write([]byte(err.Error()))
+		}
+	})))
+
+	w := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if len(w.pushed) != 1 || w.pushed[0] != "/app.css" {
+		t.Fatalf("expect /app.css to be pushed, but got %v", w.pushed)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Body.String() != http.ErrNotSupported.Error() {
+		t.Fatalf("expect http.ErrNotSupported without push support, but got %q", rec.Body.String())
+	}
+}
+
+// failedHijacker is a ResponseRecorder whose connection cannot be hijacked
+type failedHijacker struct {
+	*httptest.ResponseRecorder
+}
+
+func (w *failedHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrHijacked
+}
+
+func TestFailedHijack(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		if _, _, err := c.Writer.Hijack(); err == nil {
+			t.Fatal("expect the hijack to fail")
+		}
+		if c.Writer.Written() {
+			t.Fatal("expect the response to still be writable after a failed hijack")
+		}
+		c.String(http.StatusServiceUnavailable, "no websocket")
+	})
+
+	w := &failedHijacker{ResponseRecorder: httptest.NewRecorder()}
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "no websocket" {
+		t.Fatalf("expect 503 no websocket, but got %d %q", w.Code, w.Body.String())
+	}
+}
+
+// benchWriter is a ResponseWriter doing nothing, so the benchmarks only count the engine
+type benchWriter struct{ header http.Header }
+
+func (w *benchWriter) Header() http.Header         { return w.header }
+func (w *benchWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *benchWriter) WriteHeader(int)             {}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	r := New()
+	for _, pattern := range benchPatterns {
+		r.GET(pattern, func(c *Context) {})
+	}
+
+	for name, path := range benchPaths {
+		b.Run(name, func(b *testing.B) {
+			req := httptest.NewRequest("GET", path, nil)
+			w := &benchWriter{header: make(http.Header)}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.ServeHTTP(w, req)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package gee
+
+import (
+	"net"
+	"strings"
+)
+
+// Host returns a group whose routes only match requests for host, e.g. Host("api.example.com").
+// A label starting with ':' matches any label and is exposed through Context.Param,
+// e.g. Host(":tenant.example.com") sets the tenant param. The port of host and of the Host header is ignored.
+// Requests for a host with a Host group are only routed to that group, the routes registered
+// on the engine serve all the other hosts. Exact hosts are tried before wildcard ones.
+func (e *Engine) Host(host string) *RouterGroup {
+	host = strings.ToLower(host)
+	// example: api.example.com:8080 => api.example.com
+	if h, port, err := net.SplitHostPort(host); err == nil && isDigits(port) {
+		host = h
+	}
+
+	r := e.hosts[host]
+	for _, wild := range e.wildHosts {
+		if wild.host == host {
+			r = wild
+		}
+	}
+
+	if r == nil {
+		r = newRouter()
+		r.host = host
+		r.labels = strings.Split(host, ".")
+
+		wild := false
+		for _, label := range r.labels {
+			if label == "" || label == ":" || strings.Contains(label[1:], ":") {
+				panic("gee: invalid host pattern " + host)
+			}
+			wild = wild || label[0] == ':'
+		}
+
+		if wild {
+			e.wildHosts = append(e.wildHosts, r)
+		} else {
+			e.hosts[host] = r
+		}
+	}
+
+	return &RouterGroup{
+		parent: e.RouterGroup,
+		engine: e,
+		router: r,
+	}
+}
+
+// routerFor returns the router serving the Host header, the params of its wildcard labels are appended to params
+func (e *Engine) routerFor(host string, params Params) (*router, Params) {
+	if len(e.hosts) == 0 && len(e.wildHosts) == 0 {
+		return e.router, params
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	if r, ok := e.hosts[host]; ok {
+		return r, params
+	}
+
+	for _, r := range e.wildHosts {
+		if matched, ok := matchHost(r.labels, host, params); ok {
+			return r, matched
+		}
+	}
+
+	return e.router, params
+}
+
+// matchHost matches host label by label against the labels of a Host pattern and appends
+// the wildcard labels to params, example: labels: [:tenant example com], host: acme.example.com => [{tenant acme}]
+func matchHost(labels []string, host string, params Params) (Params, bool) {
+	start := len(params)
+
+	for i, label := range labels {
+		end := strings.IndexByte(host, '.')
+		if i == len(labels)-1 {
+			if end != -1 {
+				return params[:start], false
+			}
+			end = len(host)
+		}
+
+		if end <= 0 {
+			return params[:start], false
+		}
+
+		if label[0] == ':' {
+			params = append(params, Param{Key: label[1:], Value: host[:end]})
+		} else if label != host[:end] {
+			return params[:start], false
+		}
+
+		host = host[min(end+1, len(host)):]
+	}
+
+	return params, true
+}
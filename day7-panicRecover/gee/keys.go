@@ -0,0 +1,104 @@
+package gee
+
+import "time"
+
+// Set stores value under key for the rest of the request, e.g. the user found by an auth middleware
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+}
+
+// Get returns the value stored under key, falling back to the values of the request context
+// so what a net/http middleware stored with context.WithValue and a string key is also found
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.mu.RLock()
+	value, exists = c.Keys[key]
+	c.mu.RUnlock()
+
+	if !exists && c.Req != nil {
+		value = c.Req.Context().Value(key)
+		exists = value != nil
+	}
+
+	return value, exists
+}
+
+// MustGet returns the value stored under key, it panics if there is none
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+
+	panic("gee: key " + key + " does not exist")
+}
+
+// GetAs returns the value stored under key as a T, ok is false if there is none or it is not a T
+// example: user, ok := gee.GetAs[*User](c, "user")
+func GetAs[T any](c *Context, key string) (value T, ok bool) {
+	if v, exists := c.Get(key); exists {
+		value, ok = v.(T)
+	}
+
+	return value, ok
+}
+
+// getAs returns the value stored under key as a T, or the zero value
+func getAs[T any](c *Context, key string) T {
+	value, _ := GetAs[T](c, key)
+	return value
+}
+
+// GetString returns the value stored under key as a string, or ""
+func (c *Context) GetString(key string) string {
+	return getAs[string](c, key)
+}
+
+// GetBool returns the value stored under key as a bool, or false
+func (c *Context) GetBool(key string) bool {
+	return getAs[bool](c, key)
+}
+
+// GetInt returns the value stored under key as an int, or 0
+func (c *Context) GetInt(key string) int {
+	return getAs[int](c, key)
+}
+
+// GetInt64 returns the value stored under key as an int64, or 0
+func (c *Context) GetInt64(key string) int64 {
+	return getAs[int64](c, key)
+}
+
+// GetUint returns the value stored under key as a uint, or 0
+func (c *Context) GetUint(key string) uint {
+	return getAs[uint](c, key)
+}
+
+// GetFloat64 returns the value stored under key as a float64, or 0
+func (c *Context) GetFloat64(key string) float64 {
+	return getAs[float64](c, key)
+}
+
+// GetTime returns the value stored under key as a time.Time, or the zero time
+func (c *Context) GetTime(key string) time.Time {
+	return getAs[time.Time](c, key)
+}
+
+// GetDuration returns the value stored under key as a time.Duration, or 0
+func (c *Context) GetDuration(key string) time.Duration {
+	return getAs[time.Duration](c, key)
+}
+
+// GetStringSlice returns the value stored under key as a []string, or nil
+func (c *Context) GetStringSlice(key string) []string {
+	return getAs[[]string](c, key)
+}
+
+// GetStringMap returns the value stored under key as a map[string]interface{}, or nil
+func (c *Context) GetStringMap(key string) map[string]interface{} {
+	return getAs[map[string]interface{}](c, key)
+}
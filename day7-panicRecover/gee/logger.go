@@ -12,6 +12,9 @@ func Logger() HandlerFunc {
 		t := time.Now()
 		c.Next()
 		// Calculate resolution time
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		log.Printf("[%d] %s in %v", c.Writer.Status(), c.Req.RequestURI, time.Since(t))
+		if len(c.Errors) > 0 {
+			log.Println(c.Errors.String())
+		}
 	}
 }
@@ -0,0 +1,170 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// the formats Negotiate can render
+const (
+	MIMEJSON     = "application/json"
+	MIMEXML      = "application/xml"
+	MIMEXML2     = "text/xml"
+	MIMEHTML     = "text/html"
+	MIMEPlain    = "text/plain"
+	MIMEYAML     = "application/x-yaml"
+	MIMEYAML2    = "application/yaml"
+	MIMEPROTOBUF = "application/x-protobuf"
+)
+
+// mimeAliases are the MIME types accepted in place of each other, e.g. an offered MIMEYAML
+// matches Accept: application/yaml
+var mimeAliases = map[string]string{
+	MIMEXML:   MIMEXML2,
+	MIMEXML2:  MIMEXML,
+	MIMEYAML:  MIMEYAML2,
+	MIMEYAML2: MIMEYAML,
+}
+
+// Negotiate describes the formats a handler offers to Context.Negotiate, the data of a format
+// falls back to Data when it is nil
+type Negotiate struct {
+	Offered      []string // the offered MIME types, in order of preference
+	HTMLName     string   // the template rendered for MIMEHTML
+	HTMLData     interface{}
+	JSONData     interface{}
+	XMLData      interface{}
+	YAMLData     interface{}
+	TextData     interface{} // formatted with %v
+	ProtoBufData interface{}
+	Data         interface{}
+}
+
+// Negotiate renders the offered format preferred by the Accept header, or aborts with 406
+// example:
+//
+//	c.Negotiate(200, gee.Negotiate{Offered: []string{gee.MIMEJSON, gee.MIMEXML}, Data: user})
+func (c *Context) Negotiate(code int, offers Negotiate) {
+	c.Writer.Header().Add("Vary", "Accept")
+
+	switch format := c.NegotiateFormat(offers.Offered...); format {
+	case MIMEJSON:
+		c.JSON(code, orData(offers.JSONData, offers.Data))
+	case MIMEXML, MIMEXML2:
+		c.XML(code, orData(offers.XMLData, offers.Data))
+	case MIMEHTML:
+		c.HTML(code, offers.HTMLName, orData(offers.HTMLData, offers.Data))
+	case MIMEPlain:
+		c.String(code, "%v", orData(offers.TextData, offers.Data))
+	case MIMEYAML, MIMEYAML2:
+		c.YAML(code, orData(offers.YAMLData, offers.Data))
+	case MIMEPROTOBUF:
+		c.ProtoBuf(code, orData(offers.ProtoBufData, offers.Data))
+	case "":
+		c.AbortWithError(http.StatusNotAcceptable, errors.New("gee: none of the accepted formats is offered"))
+	default:
+		c.AbortWithError(http.StatusInternalServerError, errors.New("gee: Negotiate cannot render "+format))
+	}
+}
+
+func orData(data interface{}, fallback interface{}) interface{} {
+	if data == nil {
+		return fallback
+	}
+
+	return data
+}
+
+// NegotiateFormat returns the offered MIME type the Accept header prefers, "" when it accepts none.
+// Each offer gets the q-value of the most specific media range matching it, e.g. with
+// Accept: text/*;q=0.5, text/html the offer text/plain gets 0.5, ties go to the first offer.
+// An alias of an offer named in full, see mimeAliases, matches as the offer itself and the offer is returned.
+// Without an Accept header the first offer is returned.
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+
+	accept := c.Req.Header.Values("Accept")
+	if len(accept) == 0 {
+		return offered[0]
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := r.match(offer)
+			// only an alias named in full counts, so text/* still prefers text/html to application/xml
+			if alias, ok := mimeAliases[strings.ToLower(offer)]; ok && r.match(alias) == 2 {
+				s = 2
+			}
+
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// acceptRange is a media range of the Accept header, e.g. text/* with q 0.5
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses an Accept header, example: text/html, application/*;q=0.8
+func parseAccept(header string) []acceptRange {
+	ranges := make([]acceptRange, 0, 4)
+
+	for _, part := range strings.Split(header, ",") {
+		mediaRange, params, _ := strings.Cut(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mediaRange)), "/")
+		if !ok || typ == "" || subtype == "" {
+			continue
+		}
+
+		r := acceptRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "q") {
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				r.q = q
+			}
+		}
+		ranges = append(ranges, r)
+	}
+
+	return ranges
+}
+
+// match returns how specifically r matches the MIME type offer, 2 for type/subtype,
+// 1 for type/* and 0 for */*, or -1 when it does not match
+func (r acceptRange) match(offer string) int {
+	typ, subtype, _ := strings.Cut(strings.ToLower(offer), "/")
+
+	switch {
+	case r.typ == "*" && r.subtype == "*":
+		return 0
+	case r.typ != typ:
+		return -1
+	case r.subtype == "*":
+		return 1
+	case r.subtype == subtype:
+		return 2
+	}
+
+	return -1
+}
@@ -0,0 +1,138 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	offered := []string{MIMEJSON, MIMEXML, MIMEHTML, MIMEPlain}
+	tests := map[string]string{
+		"":                                   MIMEJSON,
+		"application/xml":                    MIMEXML,
+		"text/*":                             MIMEHTML,
+		"text/*;q=0.5, text/plain":           MIMEPlain,
+		"application/json;q=0.2, */*;q=0.9":  MIMEXML,
+		"application/*;q=0.8, text/html":     MIMEHTML,
+		"image/png":                          "",
+		"*/*;q=0":                            "",
+		"text/html;level=1;q=0.4, */*;q=0.3": MIMEHTML,
+		"text/xml":                           MIMEXML,
+		"*/*;q=0.1, text/xml":                MIMEXML,
+	}
+
+	for accept, expect := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		c := &Context{Req: req}
+
+		if format := c.NegotiateFormat(offered...); format != expect {
+			t.Fatalf("Accept %q: expect %q, but got %q", accept, expect, format)
+		}
+	}
+}
+
+type protoMessage struct{}
+
+func (protoMessage) Marshal() ([]byte, error) {
+	return []byte{0x08, 0x96, 0x01}, nil
+}
+
+func TestNegotiate(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.Negotiate(200, Negotiate{
+			Offered:      []string{MIMEJSON, MIMEYAML, MIMEPlain, MIMEPROTOBUF},
+			ProtoBufData: protoMessage{},
+			Data:         H{"name": "geektutu"},
+		})
+	})
+
+	tests := []struct {
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"application/json", 200, MIMEJSON, "{\"name\":\"geektutu\"}\n"},
+		{"application/x-yaml", 200, MIMEYAML, "name: geektutu\n"},
+		{"*/*;q=0.1, application/yaml", 200, MIMEYAML, "name: geektutu\n"},
+		{"text/plain", 200, "text/plain", "map[name:geektutu]"},
+		{"application/x-protobuf", 200, MIMEPROTOBUF, "\x08\x96\x01"},
+		{"image/png", http.StatusNotAcceptable, "", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.code || w.Header().Get("Content-Type") != tt.contentType || w.Body.String() != tt.body {
+			t.Fatalf("%s: expect %d %q %q, but got %d %q %q", tt.accept, tt.code, tt.contentType, tt.body,
+				w.Code, w.Header().Get("Content-Type"), w.Body.String())
+		}
+
+		if w.Header().Get("Vary") != "Accept" {
+			t.Fatalf("%s: expect Vary: Accept", tt.accept)
+		}
+	}
+}
+
+func TestMarshalYAML(t *testing.T) {
+	type address struct {
+		City string
+		Zip  string `yaml:"zip,omitempty"`
+	}
+	type user struct {
+		Name    string   `yaml:"name"`
+		Age     int      `yaml:"age"`
+		Tags    []string `yaml:"tags"`
+		Address *address `yaml:"address"`
+		Items   []H      `yaml:"items"`
+		Created time.Time
+		Empty   []int
+		Skip    string `yaml:"-"`
+	}
+
+	data, err := marshalYAML(user{
+		Name:    "geek: tutu",
+		Age:     20,
+		Tags:    []string{"go", "true", "1.5", "2001-12-14", "12:30", "v1:2"},
+		Address: &address{City: "Hangzhou"},
+		Items:   []H{{"id": 1, "name": "a"}, {"id": 2, "name": ""}},
+		Created: time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC),
+		Empty:   []int{},
+	})
+
+	expect := `name: "geek: tutu"
+age: 20
+tags:
+  - go
+  - "true"
+  - "1.5"
+  - "2001-12-14"
+  - "12:30"
+  - v1:2
+address:
+  city: Hangzhou
+items:
+  - id: 1
+    name: a
+  - id: 2
+    name: ""
+created: "2000-01-02T03:04:05Z"
+empty: []
+`
+	if err != nil || string(data) != expect {
+		t.Fatalf("expect\n%s\nbut got %v\n%s", expect, err, data)
+	}
+
+	if _, err := marshalYAML(H{"f": func() {}}); err == nil {
+		t.Fatal("expect an error for a func")
+	}
+}
@@ -0,0 +1,198 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query returns the first value of the query key, or ""
+func (c *Context) Query(key string) string {
+	// example: curl http://localhost:9999/?username=geektutu&password=1234
+	value, _ := c.GetQuery(key)
+	return value
+}
+
+// DefaultQuery returns the first value of the query key, or def when the key is missing
+func (c *Context) DefaultQuery(key, def string) string {
+	if value, ok := c.GetQuery(key); ok {
+		return value
+	}
+
+	return def
+}
+
+// GetQuery returns the first value of the query key, ok is false when the key is missing,
+// example: /?name= gives "", true and / gives "", false
+func (c *Context) GetQuery(key string) (string, bool) {
+	if values, ok := c.GetQueryArray(key); ok {
+		return values[0], true
+	}
+
+	return "", false
+}
+
+// QueryArray returns every value of the query key, example: /?tag=go&tag=web => [go web]
+func (c *Context) QueryArray(key string) []string {
+	values, _ := c.GetQueryArray(key)
+	return values
+}
+
+// GetQueryArray returns every value of the query key, ok is false when the key is missing
+func (c *Context) GetQueryArray(key string) ([]string, bool) {
+	values, ok := c.query()[key]
+	return values, ok && len(values) > 0
+}
+
+// QueryMap returns the query keys of the form key[name] as a map,
+// example: /?filter[status]=open&filter[owner]=me => {status: open, owner: me}
+func (c *Context) QueryMap(key string) map[string]string {
+	dict, _ := c.GetQueryMap(key)
+	return dict
+}
+
+// GetQueryMap returns the query keys of the form key[name] as a map, ok is false when there is none
+func (c *Context) GetQueryMap(key string) (map[string]string, bool) {
+	return valuesMap(c.query(), key)
+}
+
+// PostForm returns the first value of key in the urlencoded or multipart body, then in the query
+// as Request.FormValue does, or "". The other PostForm accessors only read the body.
+func (c *Context) PostForm(key string) string {
+	// example: curl http://localhost:9999/form  -X POST -d 'username=geektutu&password=1234'
+	if value, ok := c.GetPostForm(key); ok {
+		return value
+	}
+
+	return c.Query(key)
+}
+
+// DefaultPostForm returns the first value of key in the body, or def when the key is missing
+func (c *Context) DefaultPostForm(key, def string) string {
+	if value, ok := c.GetPostForm(key); ok {
+		return value
+	}
+
+	return def
+}
+
+// GetPostForm returns the first value of key in the body, ok is false when the key is missing
+func (c *Context) GetPostForm(key string) (string, bool) {
+	if values, ok := c.GetPostFormArray(key); ok {
+		return values[0], true
+	}
+
+	return "", false
+}
+
+// PostFormArray returns every value of key in the body
+func (c *Context) PostFormArray(key string) []string {
+	values, _ := c.GetPostFormArray(key)
+	return values
+}
+
+// GetPostFormArray returns every value of key in the body, ok is false when the key is missing
+func (c *Context) GetPostFormArray(key string) ([]string, bool) {
+	values, ok := c.postForm()[key]
+	return values, ok && len(values) > 0
+}
+
+// PostFormMap returns the body keys of the form key[name] as a map
+func (c *Context) PostFormMap(key string) map[string]string {
+	dict, _ := c.GetPostFormMap(key)
+	return dict
+}
+
+// GetPostFormMap returns the body keys of the form key[name] as a map, ok is false when there is none
+func (c *Context) GetPostFormMap(key string) (map[string]string, bool) {
+	return valuesMap(c.postForm(), key)
+}
+
+// QueryInt parses the query key as an int. A missing key gives 0 and no error, a value that
+// does not parse gives 0 and an error that is also attached to the Context, see Context.Error.
+func (c *Context) QueryInt(key string) (int, error) {
+	return parseQuery(c, key, strconv.Atoi)
+}
+
+// QueryBool parses the query key as a bool, e.g. 1, t, true, 0, f, false, see QueryInt for the errors
+func (c *Context) QueryBool(key string) (bool, error) {
+	return parseQuery(c, key, strconv.ParseBool)
+}
+
+// QueryDuration parses the query key as a time.Duration, e.g. 1h30m, see QueryInt for the errors
+func (c *Context) QueryDuration(key string) (time.Duration, error) {
+	return parseQuery(c, key, time.ParseDuration)
+}
+
+// QueryTime parses the query key with layout, RFC 3339 when layout is "", see QueryInt for the errors
+func (c *Context) QueryTime(key string, layout string) (time.Time, error) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+
+	return parseQuery(c, key, func(value string) (time.Time, error) {
+		return time.Parse(layout, value)
+	})
+}
+
+// parseQuery parses the query key with parse and attaches the error to the Context
+func parseQuery[T any](c *Context, key string, parse func(string) (T, error)) (T, error) {
+	var zero T
+
+	value, ok := c.GetQuery(key)
+	if !ok {
+		return zero, nil
+	}
+
+	parsed, err := parse(value)
+	if err != nil {
+		err = errors.New("gee: query " + key + ": " + err.Error())
+		c.Error(err).SetMeta(key)
+		return zero, err
+	}
+
+	return parsed, nil
+}
+
+// query parses the query string once per request
+func (c *Context) query() url.Values {
+	if c.queryCache == nil {
+		c.queryCache = c.Req.URL.Query()
+	}
+
+	return c.queryCache
+}
+
+// postForm parses the urlencoded or multipart body once per request
+func (c *Context) postForm() url.Values {
+	if c.formCache == nil {
+		// a body too large for BodyLimit already aborted with 413
+		if _, err := c.MultipartForm(); err != nil && !errors.Is(err, http.ErrNotMultipart) && !isTooLarge(err) {
+			c.Error(err)
+		}
+		c.formCache = c.Req.PostForm
+		if c.formCache == nil {
+			c.formCache = url.Values{}
+		}
+	}
+
+	return c.formCache
+}
+
+// valuesMap collects the keys key[name] of values, example: filter[status]=open => {status: open}
+func valuesMap(values url.Values, key string) (map[string]string, bool) {
+	dict := make(map[string]string)
+
+	for k, v := range values {
+		if name, ok := strings.CutPrefix(k, key+"["); ok && len(v) > 0 {
+			if name, ok = strings.CutSuffix(name, "]"); ok && name != "" {
+				dict[name] = v[0]
+			}
+		}
+	}
+
+	return dict, len(dict) > 0
+}
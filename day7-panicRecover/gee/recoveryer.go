@@ -31,6 +31,11 @@ func Recovery() HandlerFunc {
 			if err := recover(); err != nil {
 				message := fmt.Sprintf("%s", err)
 				log.Printf("%s\n\n", trace(message))
+				// 响应已经开始发送时无法再修改状态码，只阻止后面的中间件执行
+				if c.Writer.Written() {
+					c.Abort()
+					return
+				}
 				c.Fail(500, "Internal Server Error") // 阻断器阻止后面的中间件执行
 			}
 		}()
@@ -0,0 +1,393 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Render writes a response body in one format, Context.Render is the single write path of the
+// response helpers, so a new format or codec is added by implementing Render
+type Render interface {
+	// Render writes the body, the status is already set
+	Render(w http.ResponseWriter) error
+	// WriteContentType sets the Content-Type header unless the handler already set one
+	WriteContentType(w http.ResponseWriter)
+}
+
+var (
+	_ Render = JSON{}
+	_ Render = IndentedJSON{}
+	_ Render = SecureJSON{}
+	_ Render = JSONP{}
+	_ Render = AsciiJSON{}
+	_ Render = PureJSON{}
+	_ Render = XML{}
+	_ Render = YAML{}
+	_ Render = ProtoBuf{}
+	_ Render = Text{}
+	_ Render = HTML{}
+	_ Render = Redirect{}
+	_ Render = Data{}
+	_ Render = Reader{}
+)
+
+// writeContentType sets the Content-Type header of w to value if it has none
+func writeContentType(w http.ResponseWriter, value string) {
+	header := w.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", value)
+	}
+}
+
+// writeBody sets the Content-Type and writes body, the renderers encode the whole body
+// before calling it so an encoding error can still turn the response into a 500
+func writeBody(w http.ResponseWriter, contentType string, body []byte) error {
+	writeContentType(w, contentType)
+	_, err := w.Write(body)
+	return err
+}
+
+// encodeJSON encodes data like json.Encoder, with a trailing newline
+func encodeJSON(data interface{}, escapeHTML bool, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(escapeHTML)
+	encoder.SetIndent("", indent)
+
+	if err := encoder.Encode(data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// JSON renders Data as json, with <, > and & escaped for embedding in html
+type JSON struct {
+	Data interface{}
+}
+
+func (r JSON) Render(w http.ResponseWriter) error {
+	body, err := encodeJSON(r.Data, true, "")
+	if err != nil {
+		return err
+	}
+
+	return writeBody(w, MIMEJSON, body)
+}
+
+func (r JSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEJSON)
+}
+
+// IndentedJSON renders Data as json indented by four spaces, for humans reading the response
+type IndentedJSON struct {
+	Data interface{}
+}
+
+func (r IndentedJSON) Render(w http.ResponseWriter) error {
+	body, err := encodeJSON(r.Data, true, "    ")
+	if err != nil {
+		return err
+	}
+
+	return writeBody(w, MIMEJSON, body)
+}
+
+func (r IndentedJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEJSON)
+}
+
+// defaultSecureJSONPrefix is the prefix of Context.SecureJSON
+const defaultSecureJSONPrefix = "while(1);"
+
+// SecureJSON renders Data as json and prefixes a top level array with Prefix,
+// so a page of another site including the url as a script cannot read it
+type SecureJSON struct {
+	Prefix string
+	Data   interface{}
+}
+
+func (r SecureJSON) Render(w http.ResponseWriter) error {
+	body, err := encodeJSON(r.Data, true, "")
+	if err != nil {
+		return err
+	}
+
+	if bytes.HasPrefix(body, []byte("[")) {
+		body = append([]byte(r.Prefix), body...)
+	}
+
+	return writeBody(w, MIMEJSON, body)
+}
+
+func (r SecureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEJSON)
+}
+
+// JSONP renders Data as json wrapped in a call to Callback, plain json when Callback is empty
+type JSONP struct {
+	Callback string
+	Data     interface{}
+}
+
+func (r JSONP) Render(w http.ResponseWriter) error {
+	body, err := encodeJSON(r.Data, true, "")
+	if err != nil {
+		return err
+	}
+
+	if r.Callback == "" {
+		return writeBody(w, MIMEJSON, body)
+	}
+
+	callback := template.JSEscapeString(r.Callback)
+	body = append(append([]byte(callback+"("), bytes.TrimSuffix(body, []byte("\n"))...), ");"...)
+
+	return writeBody(w, "application/javascript", body)
+}
+
+func (r JSONP) WriteContentType(w http.ResponseWriter) {
+	if r.Callback == "" {
+		writeContentType(w, MIMEJSON)
+		return
+	}
+
+	writeContentType(w, "application/javascript")
+}
+
+// AsciiJSON renders Data as json with every non ASCII character escaped as \uXXXX
+type AsciiJSON struct {
+	Data interface{}
+}
+
+func (r AsciiJSON) Render(w http.ResponseWriter) error {
+	body, err := encodeJSON(r.Data, true, "")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for len(body) > 0 {
+		c, size := utf8.DecodeRune(body)
+		switch {
+		case c < utf8.RuneSelf:
+			buf.WriteByte(byte(c))
+		case c > 0xffff:
+			// a surrogate pair
+			c -= 0x10000
+			fmt.Fprintf(&buf, "\\u%04x\\u%04x", 0xd800+(c>>10), 0xdc00+(c&0x3ff))
+		default:
+			fmt.Fprintf(&buf, "\\u%04x", c)
+		}
+		body = body[size:]
+	}
+
+	return writeBody(w, MIMEJSON, buf.Bytes())
+}
+
+func (r AsciiJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEJSON)
+}
+
+// PureJSON renders Data as json without escaping <, > and &
+type PureJSON struct {
+	Data interface{}
+}
+
+func (r PureJSON) Render(w http.ResponseWriter) error {
+	body, err := encodeJSON(r.Data, false, "")
+	if err != nil {
+		return err
+	}
+
+	return writeBody(w, MIMEJSON, body)
+}
+
+func (r PureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEJSON)
+}
+
+// XML renders Data as xml
+type XML struct {
+	Data interface{}
+}
+
+func (r XML) Render(w http.ResponseWriter) error {
+	body, err := xml.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+
+	return writeBody(w, MIMEXML, body)
+}
+
+func (r XML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEXML)
+}
+
+// YAML renders Data as yaml, see marshalYAML for what is supported
+type YAML struct {
+	Data interface{}
+}
+
+func (r YAML) Render(w http.ResponseWriter) error {
+	body, err := marshalYAML(r.Data)
+	if err != nil {
+		return err
+	}
+
+	return writeBody(w, MIMEYAML, body)
+}
+
+func (r YAML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEYAML)
+}
+
+// protoMarshaler is implemented by protobuf messages, so gee does not depend on a protobuf package
+type protoMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// ProtoBuf renders Data as protobuf, Data must have a Marshal() ([]byte, error) method
+// as the messages generated by protoc do
+type ProtoBuf struct {
+	Data interface{}
+}
+
+func (r ProtoBuf) Render(w http.ResponseWriter) error {
+	m, ok := r.Data.(protoMarshaler)
+	if !ok {
+		return fmt.Errorf("gee: %T is not a protobuf message", r.Data)
+	}
+
+	body, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return writeBody(w, MIMEPROTOBUF, body)
+}
+
+func (r ProtoBuf) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEPROTOBUF)
+}
+
+// Text renders Format with fmt.Sprintf, also without Data so 100%% gives 100%
+type Text struct {
+	Format string
+	Data   []interface{}
+}
+
+func (r Text) Render(w http.ResponseWriter) error {
+	return writeBody(w, MIMEPlain, []byte(fmt.Sprintf(r.Format, r.Data...)))
+}
+
+func (r Text) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEPlain)
+}
+
+// HTML renders the template Name of Template with Data
+type HTML struct {
+	Template *template.Template
+	Name     string
+	Data     interface{}
+}
+
+func (r HTML) Render(w http.ResponseWriter) error {
+	if r.Template == nil {
+		return errors.New("gee: no html templates, see Engine.LoadHTMLGlob")
+	}
+
+	var buf bytes.Buffer
+	if err := r.Template.ExecuteTemplate(&buf, r.Name, r.Data); err != nil {
+		return err
+	}
+
+	return writeBody(w, MIMEHTML, buf.Bytes())
+}
+
+func (r HTML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEHTML)
+}
+
+// Redirect sends the client to Location with Code, a 3xx or 201, Location is sent as is
+type Redirect struct {
+	Code     int
+	Location string
+}
+
+func (r Redirect) Render(w http.ResponseWriter) error {
+	if !r.valid() {
+		return errors.New("gee: cannot redirect with status code " + strconv.Itoa(r.Code))
+	}
+
+	r.WriteContentType(w)
+	w.WriteHeader(r.Code)
+
+	return nil
+}
+
+// WriteContentType sets Location, the only header of a redirect, also for a 304 which has no body
+func (r Redirect) WriteContentType(w http.ResponseWriter) {
+	if r.valid() {
+		w.Header().Set("Location", r.Location)
+	}
+}
+
+func (r Redirect) valid() bool {
+	return r.Code >= 300 && r.Code <= 308 || r.Code == http.StatusCreated
+}
+
+// Data renders bytes with ContentType, no Content-Type is set when it is empty
+type Data struct {
+	ContentType string
+	Data        []byte
+}
+
+func (r Data) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	_, err := w.Write(r.Data)
+	return err
+}
+
+func (r Data) WriteContentType(w http.ResponseWriter) {
+	if r.ContentType != "" {
+		writeContentType(w, r.ContentType)
+	}
+}
+
+// Reader streams Reader to the client with ContentType and the extra Headers,
+// ContentLength is sent when it is not negative
+type Reader struct {
+	ContentType   string
+	ContentLength int64
+	Headers       map[string]string
+	Reader        io.Reader
+}
+
+func (r Reader) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	if r.ContentLength >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	}
+	for key, value := range r.Headers {
+		w.Header().Set(key, value)
+	}
+
+	_, err := io.Copy(w, r.Reader)
+	return err
+}
+
+func (r Reader) WriteContentType(w http.ResponseWriter) {
+	if r.ContentType != "" {
+		writeContentType(w, r.ContentType)
+	}
+}
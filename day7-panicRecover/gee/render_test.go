@@ -0,0 +1,116 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type xmlUser struct {
+	XMLName struct{} `xml:"user"`
+	Name    string   `xml:"name"`
+}
+
+func TestRenderers(t *testing.T) {
+	data := H{"html": "<b>", "name": "极客兔兔"}
+	tests := []struct {
+		name        string
+		render      Render
+		contentType string
+		body        string
+	}{
+		{"JSON", JSON{data}, MIMEJSON, "{\"html\":\"\\u003cb\\u003e\",\"name\":\"极客兔兔\"}\n"},
+		{"IndentedJSON", IndentedJSON{H{"a": 1}}, MIMEJSON, "{\n    \"a\": 1\n}\n"},
+		{"SecureJSON", SecureJSON{"while(1);", []int{1, 2}}, MIMEJSON, "while(1);[1,2]\n"},
+		{"SecureJSONObject", SecureJSON{"while(1);", H{"a": 1}}, MIMEJSON, "{\"a\":1}\n"},
+		{"JSONP", JSONP{"cb", H{"a": 1}}, "application/javascript", "cb({\"a\":1});"},
+		{"AsciiJSON", AsciiJSON{H{"name": "兔🐰"}}, MIMEJSON, "{\"name\":\"\\u5154\\ud83d\\udc30\"}\n"},
+		{"PureJSON", PureJSON{H{"html": "<b>"}}, MIMEJSON, "{\"html\":\"<b>\"}\n"},
+		{"XML", XML{xmlUser{Name: "geektutu"}}, MIMEXML, "<user><name>geektutu</name></user>"},
+		{"YAML", YAML{H{"a": 1}}, MIMEYAML, "a: 1\n"},
+		{"Text", Text{"%s is %d", []interface{}{"geektutu", 20}}, MIMEPlain, "geektutu is 20"},
+		{"TextWithoutData", Text{"100%% done", nil}, MIMEPlain, "100% done"},
+		{"Data", Data{"image/png", []byte{0x89}}, "image/png", "\x89"},
+		{"Reader", Reader{"text/csv", 3, map[string]string{"Content-Disposition": "attachment"}, strings.NewReader("a,b")}, "text/csv", "a,b"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		if err := tt.render.Render(w); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if w.Header().Get("Content-Type") != tt.contentType || w.Body.String() != tt.body {
+			t.Fatalf("%s: expect %q %q, but got %q %q", tt.name, tt.contentType, tt.body, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+}
+
+type failingRender struct{}
+
+func (failingRender) Render(http.ResponseWriter) error {
+	return errors.New("render failed")
+}
+
+func (failingRender) WriteContentType(http.ResponseWriter) {}
+
+func TestContextRender(t *testing.T) {
+	r := New()
+	r.GET("/fail", func(c *Context) {
+		c.Render(200, failingRender{})
+	})
+	r.GET("/nocontent", func(c *Context) {
+		c.JSON(http.StatusNoContent, H{"a": 1})
+	})
+	r.GET("/redirect", func(c *Context) {
+		c.Redirect(http.StatusFound, "/login")
+	})
+	r.GET("/badredirect", func(c *Context) {
+		c.Redirect(http.StatusOK, "/login")
+	})
+	r.GET("/reredirect", func(c *Context) {
+		c.Status(http.StatusNoContent)
+		c.Redirect(http.StatusFound, "/login")
+		if c.StatusCode != http.StatusFound {
+			t.Fatalf("expect StatusCode 302 after Redirect, but got %d", c.StatusCode)
+		}
+	})
+	r.GET("/percent", func(c *Context) {
+		c.String(200, "100%% done")
+	})
+	r.GET("/type", func(c *Context) {
+		c.SetHeader("Content-Type", "application/problem+json")
+		c.JSON(400, H{"title": "bad"})
+	})
+
+	tests := []struct {
+		path        string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"/fail", 500, "", ""},
+		{"/nocontent", 204, MIMEJSON, ""},
+		{"/redirect", 302, "", ""},
+		{"/badredirect", 500, "", ""},
+		{"/reredirect", 302, "", ""},
+		{"/percent", 200, MIMEPlain, "100% done"},
+		{"/type", 400, "application/problem+json", "{\"title\":\"bad\"}\n"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+		if w.Code != tt.code || w.Header().Get("Content-Type") != tt.contentType || w.Body.String() != tt.body {
+			t.Fatalf("%s: expect %d %q %q, but got %d %q %q", tt.path, tt.code, tt.contentType, tt.body,
+				w.Code, w.Header().Get("Content-Type"), w.Body.String())
+		}
+
+		if location := w.Header().Get("Location"); (tt.code == 302) != (location == "/login") {
+			t.Fatalf("%s: expect Location only on the redirects, but got %q", tt.path, location)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package gee
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+)
+
+const noWritten = -1
+
+// ResponseWriter is the writer exposed as Context.Writer, it records what the handlers sent
+// so that middlewares such as Logger and Recovery can see it
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+
+	// Status returns the status code of the response, 200 until one is set
+	Status() int
+	// Size returns the number of body bytes written, -1 until the headers are sent
+	Size() int
+	// Written reports whether the headers were sent
+	Written() bool
+	// WriteHeaderNow sends the headers with the current status code
+	WriteHeaderNow()
+	// Unwrap returns the underlying writer, for http.ResponseController
+	Unwrap() http.ResponseWriter
+}
+
+// responseWriter defers WriteHeader until the first Write or the end of the request,
+// so the status can still be changed while nothing has been sent
+type responseWriter struct {
+	http.ResponseWriter
+	size     int
+	status   int
+	bodyless bool // a HEAD request answered by a GET route, the body is dropped
+}
+
+var _ ResponseWriter = (*responseWriter)(nil)
+
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.size = noWritten
+	w.status = http.StatusOK
+	w.bodyless = false
+}
+
+// WriteHeader sets the status code, it is sent with the first Write or once the handlers return
+func (w *responseWriter) WriteHeader(code int) {
+	if code <= 0 || code == w.status {
+		return
+	}
+
+	if w.Written() {
+		log.Printf("[WARNING] headers were already written, wanted to override status code %d with %d", w.status, code)
+		return
+	}
+
+	w.status = code
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	if w.bodyless {
+		return len(b), nil
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+
+	return n, err
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+// Flush sends the headers and any buffered data to the client
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection, e.g. for websockets
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gee: the ResponseWriter does not support hijacking")
+	}
+
+	conn, rw, err := h.Hijack()
+	// nothing must be written once the connection is hijacked
+	if err == nil && w.size < 0 {
+		w.size = 0
+	}
+
+	return conn, rw, err
+}
+
+// Push starts an HTTP/2 server push, it returns http.ErrNotSupported when the underlying writer cannot push
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,184 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Route is a registered route, returned by the RouterGroup methods so it can be named
+type Route struct {
+	method   string
+	pattern  string
+	name     string
+	group    *RouterGroup  // the group that registered the route
+	own      []HandlerFunc // the handlers passed when registering the route
+	handlers []HandlerFunc // group middlewares followed by the route handlers
+
+	matchers map[string]func(string) bool // constraints of the params, set by Name for URL
+}
+
+// Name names the route so its URL can be built with Engine.URL, names must be unique
+// example: r.GET("/hello/:name", handler).Name("hello")
+func (r *Route) Name(name string) *Route {
+	e := r.group.engine
+	if other, ok := e.namedRoutes[name]; ok && other != r {
+		panic("gee: route name " + name + " is already used by " + other.method + " " + other.pattern)
+	}
+
+	r.matchers = make(map[string]func(string) bool)
+	for _, part := range strings.Split(r.pattern, "/") {
+		if part != "" && part[0] == ':' {
+			key, constraint, _ := splitParam(part)
+			r.matchers[key], _ = compileConstraint(constraint)
+		}
+	}
+
+	delete(e.namedRoutes, r.name)
+	r.name = name
+	e.namedRoutes[name] = r
+
+	return r
+}
+
+// Method returns the method of the route
+func (r *Route) Method() string {
+	return r.method
+}
+
+// Pattern returns the full pattern of the route, including the group prefix
+func (r *Route) Pattern() string {
+	return r.pattern
+}
+
+// URL builds the path of the route named name, params are key/value pairs filling its wildcards
+// example: e.URL("hello", "name", "geektutu") => /hello/geektutu, e.URL("assets", "filepath", "css/a.css")
+// :param values are path escaped, *catchall values keep their '/' and have each segment escaped,
+// one leading '/' of a *catchall value is dropped as the pattern already has it, e.g. /a/b => a/b
+func (e *Engine) URL(name string, params ...string) (string, error) {
+	route, ok := e.namedRoutes[name]
+	if !ok {
+		return "", errors.New("gee: no route named " + name)
+	}
+
+	if len(params)%2 != 0 {
+		return "", errors.New("gee: URL " + name + ": params must be key/value pairs")
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	parts := strings.Split(route.pattern, "/")
+	for i, part := range parts {
+		if part == "" || part[0] != ':' && part[0] != '*' {
+			continue
+		}
+
+		key := paramName(part)
+		value, ok := values[key]
+		if !ok {
+			return "", errors.New("gee: URL " + name + ": missing param " + key)
+		}
+		delete(values, key)
+
+		if part[0] == '*' {
+			segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for j, segment := range segments {
+				segments[j] = url.PathEscape(segment)
+			}
+			parts[i] = strings.Join(segments, "/")
+			continue
+		}
+
+		// the URL must route back to the same route
+		if match := route.matchers[key]; value == "" || match != nil && !match(value) {
+			return "", errors.New("gee: URL " + name + ": invalid value " + value + " for " + part)
+		}
+		parts[i] = url.PathEscape(value)
+	}
+
+	for key := range values {
+		return "", errors.New("gee: URL " + name + ": unknown param " + key)
+	}
+
+	return strings.Join(parts, "/"), nil
+}
+
+// RouteInfo describes a registered route, see Engine.Routes
+type RouteInfo struct {
+	Method      string   `json:"method"`
+	Pattern     string   `json:"pattern"`
+	Host        string   `json:"host,omitempty"` // the Host pattern, empty for engine routes
+	Name        string   `json:"name,omitempty"`
+	Handler     string   `json:"handler"`     // the last handler of the chain
+	Group       string   `json:"group"`       // prefix of the group that registered the route
+	Middlewares []string `json:"middlewares"` // group and route middlewares, in the order they run
+}
+
+// Routes returns every registered route sorted by host, pattern and method,
+// so the output of two releases can be diffed
+func (e *Engine) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(e.routes))
+
+	for _, route := range e.routes {
+		last := len(route.handlers) - 1
+		middlewares := make([]string, last)
+		for i, h := range route.handlers[:last] {
+			middlewares[i] = nameOfFunction(h)
+		}
+
+		routes = append(routes, RouteInfo{
+			Method:      route.method,
+			Pattern:     route.pattern,
+			Host:        route.group.router.host,
+			Name:        route.name,
+			Handler:     nameOfFunction(route.handlers[last]),
+			Group:       route.group.prefix,
+			Middlewares: middlewares,
+		})
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Host != routes[j].Host {
+			return routes[i].Host < routes[j].Host
+		}
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
+
+// PrintRoutes writes the route table to w, one route per line
+func (e *Engine) PrintRoutes(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tHOST\tPATTERN\tNAME\tGROUP\tHANDLER\tMIDDLEWARES")
+
+	for _, route := range e.Routes() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", route.Method, route.Host, route.Pattern, route.Name,
+			route.Group, route.Handler, strings.Join(route.Middlewares, ", "))
+	}
+
+	return tw.Flush()
+}
+
+// RoutesJSON returns the routes as an indented JSON array
+func (e *Engine) RoutesJSON() ([]byte, error) {
+	return json.MarshalIndent(e.Routes(), "", "  ")
+}
+
+// nameOfFunction returns the full name of a handler, e.g. Gee/day7-panicRecover/gee.Logger.func1
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
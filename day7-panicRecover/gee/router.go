@@ -1,106 +1,346 @@
 package gee
 
-import "strings"
+import (
+	"net/url"
+	pathpkg "path"
+	"slices"
+	"sort"
+	"strings"
+)
 
 type router struct {
-	roots    map[string]*node
-	handlers map[string]HandlerFunc
+	roots  map[string]*node
+	noAuto map[string]bool
+	host   string   // the Host pattern of the router, empty for the engine router
+	labels []string // the labels of host, eg, [:tenant example com]
 }
 
-// roots key eg, roots['GET'] roots['POST']
-// handlers key eg, handlers['GET-/p/:lang'] handlers['POST-/p/book']
+// roots key eg, roots['GET'] roots['POST'], each route keeps its handler chain
+// (with the group middlewares already in front) on the node where its pattern ends
+// noAuto key eg, noAuto['HEAD-/p/:lang'] opts /p/:lang out of the automatic HEAD handler
 
 // newRouter returns a new router
 func newRouter() *router {
 	return &router{
-		roots:    make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
+		roots:  make(map[string]*node),
+		noAuto: make(map[string]bool),
 	}
 }
 
-// only one * is allowed in a pattern
-func parsePattern(pattern string) []string {
-	vs := strings.Split(pattern, "/") // example: /p/:lang => ["", "p", ":lang"]
-
-	parts := make([]string, 0)
+// RouteError reports a pattern that cannot be registered, either because of its
+// syntax or because it conflicts with an existing route of the same method
+type RouteError struct {
+	Method   string
+	Pattern  string // the pattern being registered
+	Existing string // the registered pattern it conflicts with, empty for syntax errors
+	Reason   string
+}
 
-	for _, item := range vs {
-		if item != "" {
-			parts = append(parts, item)
-			if item[0] == '*' {
-				break
-			}
-		}
+func (e *RouteError) Error() string {
+	msg := "gee: " + e.Method + " " + e.Pattern + ": " + e.Reason
+	if e.Existing != "" {
+		msg += " (existing route " + e.Existing + ")"
 	}
 
-	return parts
+	return msg
 }
 
-// addRoute adds a route to the router
-func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
-	parts := parsePattern(pattern)
-
-	key := method + "-" + pattern
+// addRoute adds a route to the router, the router is left unchanged on error
+func (r *router) addRoute(method string, pattern string, handlers ...HandlerFunc) error {
+	if pattern == "" || pattern[0] != '/' {
+		return &RouteError{Method: method, Pattern: pattern, Reason: "pattern must begin with '/'"}
+	}
 
-	_, ok := r.roots[method]
+	root, ok := r.roots[method]
 
 	if !ok {
-		r.roots[method] = &node{}
+		root = &node{}
 	}
 
-	r.roots[method].insert(pattern, parts, 0)
-	r.handlers[key] = handler
+	n, err := root.insert(pattern)
+	if err != nil {
+		err.(*RouteError).Method = method
+		return err
+	}
+
+	r.roots[method] = root
+	n.handlers = handlers
+
+	return nil
 }
 
 // getRoute gets a route from the router
-func (r *router) getRoute(method string, path string) (*node, map[string]string) {
-	searchParts := parsePattern(path)
-	params := make(map[string]string) // /:lang example: key: lang(:lang), value: go
-
+func (r *router) getRoute(method string, path string) (*node, Params) {
 	root, ok := r.roots[method]
 
 	if !ok {
 		return nil, nil // no route
 	}
 
-	n := root.search(searchParts, 0)
+	n := root.search(path)
 
-	// find params
-	if n != nil {
-		parts := parsePattern(n.pattern)
+	if n == nil {
+		return nil, nil
+	}
 
-		for index, part := range parts {
-			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
-			}
+	return n, extractParams(n.pattern, path, nil)
+}
+
+// extractParams walks a matched pattern and path side by side and appends the params to params,
+// nothing is allocated when the pattern has no params or params has enough capacity
+// example: pattern: /p/:lang/*filepath, path: /p/go/doc/intro => [{lang go} {filepath doc/intro}]
+func extractParams(pattern string, path string, params Params) Params {
+
+	// both start with '/', a wildcard always follows a '/'
+	for i, j := 1, 1; i < len(pattern); {
+		if pattern[i-1] != '/' || pattern[i] != ':' && pattern[i] != '*' {
+			i++
+			j++
+			continue
+		}
+
+		// a catch-all takes the rest of the path
+		k, l := segmentEnd(pattern, i), segmentEnd(path, j)
+		if pattern[i] == '*' {
+			l = len(path)
+		}
+
+		if k > i+1 {
+			params = append(params, Param{Key: paramName(pattern[i:k]), Value: path[j:l]})
+		}
+
+		if pattern[i] == '*' {
+			break
+		}
+
+		i, j = k, l
+	}
+
+	return params
+}
+
+// unescapeParams unescapes the values matched on an escaped path, e.g. a%2Fb => a/b,
+// values that are not valid escapes are kept as they are
+func unescapeParams(params Params) {
+	for i := range params {
+		if unescaped, err := url.PathUnescape(params[i].Value); err == nil {
+			params[i].Value = unescaped
+		}
+	}
+}
+
+// countParams returns the number of wildcards in pattern, example: /p/:lang/*filepath => 2
+func countParams(pattern string) int {
+	return strings.Count(pattern, "/:") + strings.Count(pattern, "/*")
+}
+
+// segmentEnd returns the index of the next '/' in s from i, or len(s)
+func segmentEnd(s string, i int) int {
+	if end := strings.IndexByte(s[i:], '/'); end != -1 {
+		return i + end
+	}
+
+	return len(s)
+}
+
+// allowed returns the sorted methods whose trie matches path, plus HEAD and OPTIONS
+// when the engine answers them automatically for the matched routes.
+// An empty slice means no method matches.
+func (r *router) allowed(e *Engine, path string) []string {
+	methods := make([]string, 0, len(r.roots)+2)
+	autoHEAD, autoOPTIONS := false, false
+
+	for method, root := range r.roots {
+		n := root.search(path)
+		if n == nil {
+			continue
+		}
+
+		methods = append(methods, method)
+
+		if method == "GET" && e.HandleHEAD && !r.noAuto["HEAD-"+n.pattern] {
+			autoHEAD = true
+		}
+
+		if e.HandleOPTIONS && !r.noAuto["OPTIONS-"+n.pattern] {
+			autoOPTIONS = true
+		}
+	}
+
+	if autoHEAD && !slices.Contains(methods, "HEAD") {
+		methods = append(methods, "HEAD")
+	}
+
+	if autoOPTIONS && !slices.Contains(methods, "OPTIONS") {
+		methods = append(methods, "OPTIONS")
+	}
 
-			if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
-				break
+	sort.Strings(methods)
+
+	return methods
+}
+
+// match finds the node of method for path, HEAD falls back to the GET route unless it opted out.
+// It also returns the method of the matched route.
+func (r *router) match(e *Engine, method string, path string) (*node, string) {
+	if root, ok := r.roots[method]; ok {
+		if n := root.search(path); n != nil {
+			return n, method
+		}
+	}
+
+	if method == "HEAD" && e.HandleHEAD {
+		if root, ok := r.roots["GET"]; ok {
+			if n := root.search(path); n != nil && !r.noAuto["HEAD-"+n.pattern] {
+				return n, "GET"
 			}
 		}
+	}
 
-		return n, params
+	return nil, method
+}
+
+// fixedPath returns the path to redirect to when path does not match a route of method, but does
+// once its trailing slash is toggled, or once it is cleaned and matched ignoring case.
+// It returns "" when no redirect applies.
+func (r *router) fixedPath(e *Engine, method string, path string) string {
+	if method == "CONNECT" || path == "/" {
+		return ""
+	}
+
+	if e.RedirectTrailingSlash {
+		if n, _ := r.match(e, method, toggleTrailingSlash(path)); n != nil {
+			return toggleTrailingSlash(path)
+		}
 	}
 
-	return nil, nil
+	if !e.RedirectFixedPath {
+		return ""
+	}
+
+	candidates := []string{cleanPath(path)}
+	if e.RedirectTrailingSlash && candidates[0] != "/" {
+		candidates = append(candidates, toggleTrailingSlash(candidates[0]))
+	}
+
+	methods := []string{method}
+	if method == "HEAD" && e.HandleHEAD {
+		methods = append(methods, "GET")
+	}
+
+	for _, candidate := range candidates {
+		for _, m := range methods {
+			if root, ok := r.roots[m]; ok {
+				if fixed := root.searchFold(candidate, nil); fixed != nil && string(fixed) != path {
+					return string(fixed)
+				}
+			}
+		}
+	}
+
+	return ""
 }
 
 // handle handles the incoming HTTP request by finding the appropriate route and executing the associated handlers.
-// It sets the parameters and handlers in the context based on the matched route.
-// If no route is found, it sets a default 404 handler.
+// It sets the parameters and the handler chain stored with the matched route in the context.
+// HEAD falls back to the GET route with the body discarded, and OPTIONS is answered with the Allow header.
+// A path that matches after fixing its trailing slash, case or extra segments is redirected.
+// If the path only matches under other methods, it sets the Allow header and the NoMethod handlers,
+// otherwise it sets the NoRoute handlers, all behind the engine middlewares.
 // Finally, it calls the Next method of the context to proceed to the next middleware or handler.
 func (r *router) handle(c *Context) {
-	n, params := r.getRoute(c.Method, c.Path)
+	e := c.engine
+	path := c.Path
+	if e.UseRawPath {
+		path = c.Req.URL.EscapedPath()
+	}
+	if e.RemoveExtraSlash {
+		path = cleanPath(path)
+	}
+
+	n, method := r.match(e, c.Method, path)
 
 	if n != nil {
-		c.Params = params
-		key := c.Method + "-" + n.pattern
-		c.handlers = append(c.handlers, r.handlers[key])
+		// HEAD runs the GET handler, the body is dropped by the writer
+		if method != c.Method {
+			c.writermem.bodyless = true
+		}
+		// the params of the Host labels are already in c.Params
+		start := len(c.Params)
+		c.Params = extractParams(n.pattern, path, c.Params)
+		if e.UseRawPath && e.UnescapePathValues {
+			unescapeParams(c.Params[start:])
+		}
+		c.handlers = n.handlers
+		c.Next()
+		return
+	}
+
+	if fixed := r.fixedPath(e, method, path); fixed != "" {
+		c.handlers = e.combineHandlers([]HandlerFunc{func(c *Context) {
+			redirect(c, fixed)
+		}})
+		c.Next()
+		return
+	}
+
+	allow := r.allowed(e, path)
+
+	if method == "OPTIONS" && slices.Contains(allow, "OPTIONS") {
+		c.handlers = e.combineHandlers([]HandlerFunc{func(c *Context) {
+			c.SetHeader("Allow", strings.Join(allow, ", "))
+			c.Status(204)
+		}})
+	} else if len(allow) > 0 && e.HandleMethodNotAllowed {
+		c.SetHeader("Allow", strings.Join(allow, ", "))
+		c.handlers = e.allNoMethod
 	} else {
-		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(404, "404 NOT FOUND: %s\n", c.Path)
-		})
+		c.handlers = e.allNoRoute
 	}
 	c.Next()
 }
+
+// redirect sends the client to path with the query kept, 301 for GET and HEAD
+// and 308 for the other methods so the client repeats the method and body
+func redirect(c *Context, path string) {
+	code := 308
+	if c.Method == "GET" || c.Method == "HEAD" {
+		code = 301
+	}
+
+	if c.Req.URL.RawQuery != "" {
+		path += "?" + c.Req.URL.RawQuery
+	}
+
+	c.Redirect(code, path)
+}
+
+// toggleTrailingSlash adds a trailing slash to p, or removes it if there is one
+func toggleTrailingSlash(p string) string {
+	if strings.HasSuffix(p, "/") {
+		return p[:len(p)-1]
+	}
+
+	return p + "/"
+}
+
+// cleanPath removes duplicate slashes and resolves . and .. elements, keeping the trailing slash
+// example: //p/../hello/ => /hello/
+func cleanPath(p string) string {
+	cleaned := pathpkg.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
+
+// notFound is the default NoRoute handler
+func notFound(c *Context) {
+	c.String(404, "404 NOT FOUND: %s\n", c.Path)
+}
+
+// methodNotAllowed is the default NoMethod handler, the Allow header is already set
+func methodNotAllowed(c *Context) {
+	c.String(405, "405 METHOD NOT ALLOWED: %s\n", c.Path)
+}
@@ -2,82 +2,348 @@ package gee
 
 import "strings"
 
-// trie 树节点
+// 节点类型, 查找时的优先级为 static > param > catchAll
+type nodeType uint8
+
+const (
+	nodeStatic   nodeType = iota // 静态节点, 例如 /p/
+	nodeParam                    // 参数节点, 例如 :lang
+	nodeCatchAll                 // 通配节点, 例如 *filepath
+)
+
+// radix 树节点
 type node struct {
-	pattern  string  // 待匹配路由, 例如 /p/:lang
-	part     string  // 路由中的一部分, 例如 :lang
-	children []*node // 子节点, 例如 [doc, tutorial, intro]
-	isWild   bool    // 是否精确匹配, part 含有 : 或 * 时为true
+	path          string            // 静态节点为压缩后的公共前缀, 例如 /hello/; 通配节点为整段, 例如 :lang
+	pattern       string            // 待匹配路由, 只有路由的终点才有值, 例如 /p/:lang
+	handlers      []HandlerFunc     // 路由的处理链, 与 pattern 一起设置
+	nType         nodeType          // 节点类型
+	indices       string            // 静态子节点 path 的首字节, 与 children 一一对应
+	children      []*node           // 静态子节点, 例如 [doc, tutorial, intro]
+	paramChildren []*node           // 参数子节点, 带约束的在前, 例如 [:id<int>, :name]
+	catchAllChild *node             // 通配子节点, 例如 *filepath
+	match         func(string) bool // 参数节点的约束, 为 nil 时匹配任意非空的段
 }
 
-// 第一个匹配成功的节点, 用于插入
-func (n *node) matchChild(part string) *node {
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			return child
+// 插入路由, 返回路由的终点节点(example: pattern: /p/:lang/doc)
+// 冲突只会出现在已有的节点上, 而拆分或新建节点之后都是新的分支, 所以返回错误时树没有被修改
+func (n *node) insert(pattern string) (*node, error) {
+	if err := validatePattern(pattern); err != nil {
+		return nil, err
+	}
+
+	cur, path := n, pattern
+
+	for len(path) > 0 {
+		// 静态部分, 直到下一个以 : 或 * 开头的段
+		if end := wildcardIndex(path); end != 0 {
+			if end == -1 {
+				end = len(path)
+			}
+			cur = cur.insertStatic(path[:end])
+			path = path[end:]
+			continue
 		}
+
+		end := strings.IndexByte(path, '/')
+		if end == -1 {
+			end = len(path)
+		}
+
+		if path[0] == ':' {
+			child, err := cur.insertParam(path[:end])
+			if err != nil {
+				err.Pattern = pattern
+				return nil, err
+			}
+			cur, path = child, path[end:]
+			continue
+		}
+
+		if cur.catchAllChild == nil {
+			cur.catchAllChild = &node{path: path, nType: nodeCatchAll}
+		}
+		if cur.catchAllChild.path != path {
+			return nil, &RouteError{Pattern: pattern, Existing: cur.catchAllChild.pattern,
+				Reason: "wildcard " + path + " conflicts with " + cur.catchAllChild.path}
+		}
+		cur = cur.catchAllChild
+		break
 	}
+
+	// 已经存在, example: /p/:lang 和 /p/:lang
+	if cur.pattern != "" {
+		return nil, &RouteError{Pattern: pattern, Existing: cur.pattern, Reason: "duplicate pattern"}
+	}
+
+	cur.pattern = pattern
+	return cur, nil
+}
+
+// 插入参数段, 返回对应的参数子节点(example: seg: :id<int>)
+// 同一位置约束相同而参数名不同时冲突, example: /p/:lang/x 和 /p/:name/y; 约束不同的参数可以共存
+func (n *node) insertParam(seg string) (*node, *RouteError) {
+	name, constraint, _ := splitParam(seg)
+
+	for _, child := range n.paramChildren {
+		if child.path == seg {
+			return child, nil
+		}
+
+		childName, childConstraint, _ := splitParam(child.path)
+		if childConstraint == constraint && childName != name {
+			return nil, &RouteError{Existing: child.anyPattern(),
+				Reason: "wildcard " + seg + " conflicts with " + child.path}
+		}
+	}
+
+	// validatePattern 已经检查过约束
+	match, _ := compileConstraint(constraint)
+	child := &node{path: seg, nType: nodeParam, match: match}
+
+	// 带约束的参数排在不带约束的参数之前
+	i := len(n.paramChildren)
+	for i > 0 && match != nil && n.paramChildren[i-1].match == nil {
+		i--
+	}
+	n.paramChildren = append(n.paramChildren[:i], append([]*node{child}, n.paramChildren[i:]...)...)
+
+	return child, nil
+}
+
+// 检查通配符的语法: 名字不能为空, 每段只能有一个通配符, * 只能是最后一段, 约束必须闭合且能编译
+// 在修改树之前调用, 所以无效的 pattern 不会留下节点
+func validatePattern(pattern string) error {
+	parts := strings.Split(pattern, "/")
+
+	for i, part := range parts {
+		if part == "" || part[0] != ':' && part[0] != '*' {
+			continue
+		}
+
+		name, constraint, err := splitParam(part)
+		if err != nil {
+			return &RouteError{Pattern: pattern, Reason: err.Error()}
+		}
+
+		if _, err := compileConstraint(constraint); err != nil {
+			return &RouteError{Pattern: pattern, Reason: "invalid constraint in " + part + ": " + err.Error()}
+		}
+
+		if name == "" {
+			return &RouteError{Pattern: pattern, Reason: "wildcard " + part + " must have a name"}
+		}
+
+		if strings.ContainsAny(name, ":*") {
+			return &RouteError{Pattern: pattern, Reason: "only one wildcard is allowed in segment " + part}
+		}
+
+		if part[0] == '*' && len(name) != len(part)-1 {
+			return &RouteError{Pattern: pattern, Reason: "catch-all " + part + " cannot have a constraint"}
+		}
+
+		if part[0] == '*' && i != len(parts)-1 {
+			return &RouteError{Pattern: pattern, Reason: "catch-all " + part + " must be the last segment"}
+		}
+	}
+
 	return nil
 }
 
-// 所有匹配成功的节点, 用于查找
-func (n *node) matchChildren(part string) []*node {
-	nodes := make([]*node, 0)
+// 子树中的任意一个路由, 用于报告冲突
+func (n *node) anyPattern() string {
+	if n.pattern != "" {
+		return n.pattern
+	}
 
 	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			nodes = append(nodes, child)
+		if pattern := child.anyPattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	for _, child := range n.paramChildren {
+		if pattern := child.anyPattern(); pattern != "" {
+			return pattern
 		}
 	}
 
-	return nodes
+	if n.catchAllChild != nil {
+		return n.catchAllChild.anyPattern()
+	}
+
+	return ""
 }
 
-// 插入节点(example: pattern: /p/:lang, parts: [p, :lang], height: 0)
-func (n *node) insert(pattern string, parts []string, height int) {
-	// 递归终止条件
-	if len(parts) == height {
-		// 已经存在, example: /p/:lang 和 /p/a
-		if n.pattern != "" {
-			panic("duplicate pattern: " + n.pattern + " " + pattern)
-		}
+// 遍历子树中的所有节点, 拆分会移动节点, 所以不能保存节点指针
+func (n *node) walk(fn func(*node)) {
+	fn(n)
 
-		n.pattern = pattern
-		return
+	for _, child := range n.children {
+		child.walk(fn)
 	}
 
-	part := parts[height]
-	child := n.matchChild(part)
+	for _, child := range n.paramChildren {
+		child.walk(fn)
+	}
 
-	// 不存在则创建
-	if child == nil {
-		// 不要变成 :=, 否则会创建局部变量
-		child = &node{part: part, isWild: part[0] == ':' || part[0] == '*'}
-		n.children = append(n.children, child)
+	if n.catchAllChild != nil {
+		n.catchAllChild.walk(fn)
 	}
+}
 
-	// 递归
-	child.insert(pattern, parts, height+1)
+// 插入静态部分, 必要时拆分已有节点, 返回 path 结束处的节点
+func (n *node) insertStatic(path string) *node {
+	for {
+		i := strings.IndexByte(n.indices, path[0])
+
+		// 不存在则创建
+		if i == -1 {
+			child := &node{path: path}
+			n.indices += string(path[0])
+			n.children = append(n.children, child)
+			return child
+		}
+
+		child := n.children[i]
+		l := commonPrefix(child.path, path)
+
+		// 只共享一部分前缀, example: 已有 /hello/, 插入 /hi/ 时拆分为 /h + [ello/, i/]
+		if l < len(child.path) {
+			child.split(l)
+		}
+
+		if l == len(path) {
+			return child
+		}
+
+		n, path = child, path[l:]
+	}
+}
+
+// 在 i 处拆分静态节点, 前半部分留在 n 中, 后半部分成为 n 唯一的子节点
+func (n *node) split(i int) {
+	child := *n
+	child.path = n.path[i:]
+
+	*n = node{
+		path:     n.path[:i],
+		indices:  child.path[:1],
+		children: []*node{&child},
+	}
 }
 
-// 查找节点(example: parts: [p, :lang], height: 0)
-func (n *node) search(parts []string, height int) *node {
-	if len(parts) == height || strings.HasPrefix(n.part, "*") { // 匹配到了或者遇到通配符
-		if n.pattern == "" {
+// 查找节点, path 为包含当前节点在内的剩余路径(example: path: /p/go/doc)
+// 静态子节点优先, 匹配失败时回溯到参数子节点, 最后是通配子节点
+func (n *node) search(path string) *node {
+	switch n.nType {
+	case nodeStatic:
+		if !strings.HasPrefix(path, n.path) {
+			return nil
+		}
+		path = path[len(n.path):]
+	case nodeParam:
+		end := strings.IndexByte(path, '/')
+		if end == -1 {
+			end = len(path)
+		}
+		// 参数不能为空, 且要满足约束
+		if end == 0 || n.match != nil && !n.match(path[:end]) {
 			return nil
 		}
+		path = path[end:]
+	case nodeCatchAll:
 		return n
 	}
 
-	part := parts[height]
-	children := n.matchChildren(part)
+	// 通配参数不能为空, example: /assets/ 不匹配 /assets/*filepath
+	if path == "" {
+		if n.pattern != "" {
+			return n
+		}
+		return nil
+	}
+
+	if i := strings.IndexByte(n.indices, path[0]); i != -1 {
+		if result := n.children[i].search(path); result != nil {
+			return result
+		}
+	}
+
+	for _, child := range n.paramChildren {
+		if result := child.search(path); result != nil {
+			return result
+		}
+	}
+
+	return n.catchAllChild
+}
+
+// 忽略大小写查找, 返回按路由修正了大小写的路径, 参数部分保持原样; 没有匹配时返回 nil
+// fixed 为已经匹配的部分(example: path: /HELLO/Geektutu => /hello/Geektutu)
+func (n *node) searchFold(path string, fixed []byte) []byte {
+	switch n.nType {
+	case nodeStatic:
+		if len(path) < len(n.path) || !strings.EqualFold(path[:len(n.path)], n.path) {
+			return nil
+		}
+		fixed = append(fixed, n.path...)
+		path = path[len(n.path):]
+	case nodeParam:
+		end := strings.IndexByte(path, '/')
+		if end == -1 {
+			end = len(path)
+		}
+		if end == 0 || n.match != nil && !n.match(path[:end]) {
+			return nil
+		}
+		fixed = append(fixed, path[:end]...)
+		path = path[end:]
+	case nodeCatchAll:
+		return append(fixed, path...)
+	}
+
+	if path == "" {
+		if n.pattern != "" {
+			return fixed
+		}
+		return nil
+	}
+
+	// 首字节的大小写可能不同, 所以逐个尝试静态子节点
+	for _, child := range n.children {
+		if result := child.searchFold(path, fixed); result != nil {
+			return result
+		}
+	}
 
-	for _, child := range children {
-		result := child.search(parts, height+1)
-		if result != nil {
+	for _, child := range n.paramChildren {
+		if result := child.searchFold(path, fixed); result != nil {
 			return result
 		}
 	}
 
+	if n.catchAllChild != nil {
+		return append(fixed, path...)
+	}
+
 	return nil
 }
+
+// 第一个以 : 或 * 开头的段的位置, 没有则返回 -1
+func wildcardIndex(path string) int {
+	for i := 0; i < len(path); i++ {
+		if (path[i] == ':' || path[i] == '*') && (i == 0 || path[i-1] == '/') {
+			return i
+		}
+	}
+	return -1
+}
+
+// a 和 b 公共前缀的长度
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,325 @@
+package gee
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchPriority(t *testing.T) {
+	root := &node{}
+	patterns := []string{
+		"/",
+		"/hello/:name",
+		"/hello/b/c",
+		"/hello/b/:x/d",
+		"/hi/:name",
+		"/hi/:name/*rest",
+		"/assets/*filepath",
+		"/p/a",
+		"/p/:lang",
+		"/p/*filepath",
+	}
+
+	for _, pattern := range patterns {
+		if _, err := root.insert(pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]string{
+		"/":                 "/",
+		"/hello/geektutu":   "/hello/:name",
+		"/hello/b":          "/hello/:name",
+		"/hello/b/c":        "/hello/b/c",
+		"/hello/b/e/d":      "/hello/b/:x/d",
+		"/hi/go":            "/hi/:name",
+		"/hi/go/doc/intro":  "/hi/:name/*rest",
+		"/assets/":          "",
+		"/assets/css/a.css": "/assets/*filepath",
+		"/p/a":              "/p/a",
+		"/p/abc":            "/p/:lang",
+		"/p/go/doc":         "/p/*filepath",
+		"/hello":            "",
+		"/hello/":           "",
+		"/hello/b/c/e":      "",
+		"/unknown":          "",
+	}
+
+	for path, expect := range tests {
+		n := root.search(path)
+
+		if expect == "" {
+			if n != nil {
+				t.Fatalf("%s: expect no match, but got %s", path, n.pattern)
+			}
+			continue
+		}
+
+		if n == nil || n.pattern != expect {
+			t.Fatalf("%s: expect %s, but got %v", path, expect, n)
+		}
+	}
+}
+
+func TestInsertConflicts(t *testing.T) {
+	root := &node{}
+	for _, pattern := range []string{"/p/:lang/x", "/assets/*filepath", "/p/book"} {
+		if _, err := root.insert(pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]string{
+		"/p/:name/y":        "/p/:lang/x",
+		"/assets/*file":     "/assets/*filepath",
+		"/p/book":           "/p/book",
+		"/p/*name/x":        "",
+		"/p/:":              "",
+		"/p/:lang:name":     "",
+		"/p/:lang/x/*":      "",
+		"/static/*filepath": "-",
+	}
+
+	for pattern, existing := range tests {
+		_, err := root.insert(pattern)
+
+		if existing == "-" {
+			if err != nil {
+				t.Fatalf("%s: expect no error, but got %v", pattern, err)
+			}
+			continue
+		}
+
+		routeErr, ok := err.(*RouteError)
+		if !ok {
+			t.Fatalf("%s: expect a *RouteError, but got %v", pattern, err)
+		}
+
+		if routeErr.Existing != existing {
+			t.Fatalf("%s: expect conflict with %q, but got %q", pattern, existing, routeErr.Existing)
+		}
+	}
+
+	// the failed inserts must leave the tree unchanged
+	if n := root.search("/p/go/y"); n != nil {
+		t.Fatalf("expect no match for /p/go/y, but got %s", n.pattern)
+	}
+
+	count := func() (nodes int) {
+		root.walk(func(*node) { nodes++ })
+		return nodes
+	}
+
+	before := count()
+	if _, err := root.insert("/new/:id<(>"); err == nil {
+		t.Fatal("expect an error for an invalid constraint")
+	}
+	if after := count(); after != before {
+		t.Fatalf("expect %d nodes after an invalid constraint, but got %d", before, after)
+	}
+}
+
+func TestConstrainedParams(t *testing.T) {
+	root := &node{}
+	patterns := []string{
+		"/users/:id<int>",
+		"/users/:name",
+		"/users/:uid{uuid}",
+		"/files/:file<[a-z0-9-]+>",
+	}
+
+	for _, pattern := range patterns {
+		if _, err := root.insert(pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]string{
+		"/users/42":       "/users/:id<int>",
+		"/users/geektutu": "/users/:name",
+		"/users/0f8fad5b-d9cb-469f-a165-70867728950e": "/users/:uid{uuid}",
+		"/files/report-2024":                          "/files/:file<[a-z0-9-]+>",
+		"/files/Report.pdf":                           "",
+	}
+
+	for path, expect := range tests {
+		n := root.search(path)
+
+		if expect == "" {
+			if n != nil {
+				t.Fatalf("%s: expect no match, but got %s", path, n.pattern)
+			}
+			continue
+		}
+
+		if n == nil || n.pattern != expect {
+			t.Fatalf("%s: expect %s, but got %v", path, expect, n)
+		}
+	}
+
+	if params := extractParams("/users/:id<int>", "/users/42", nil); params.ByName("id") != "42" {
+		t.Fatalf("expect id=42, but got %v", params)
+	}
+
+	for _, pattern := range []string{"/users/:uid<int>", "/users/:v{version}", "/users/:id<[a-z>", "/users/:id<(>"} {
+		if _, err := root.insert(pattern); err == nil {
+			t.Fatalf("%s: expect an error", pattern)
+		}
+	}
+}
+
+func TestExtractParams(t *testing.T) {
+	params := extractParams("/p/:lang/*filepath", "/p/go/doc/intro", nil)
+
+	if params.ByName("lang") != "go" || params.ByName("filepath") != "doc/intro" {
+		t.Fatalf("expect lang=go filepath=doc/intro, but got %v", params)
+	}
+
+	if params := extractParams("/p/book", "/p/book", nil); params != nil {
+		t.Fatalf("expect no params, but got %v", params)
+	}
+}
+
+// only one * is allowed in a pattern
+func parsePattern(pattern string) []string {
+	vs := strings.Split(pattern, "/") // example: /p/:lang => ["", "p", ":lang"]
+
+	parts := make([]string, 0)
+
+	for _, item := range vs {
+		if item != "" {
+			parts = append(parts, item)
+			if item[0] == '*' {
+				break
+			}
+		}
+	}
+
+	return parts
+}
+
+// legacyNode is the segment trie replaced by the radix tree, kept as a benchmark baseline
+type legacyNode struct {
+	pattern  string
+	part     string
+	children []*legacyNode
+	isWild   bool
+}
+
+func (n *legacyNode) matchChild(part string) *legacyNode {
+	for _, child := range n.children {
+		if child.part == part || child.isWild {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *legacyNode) matchChildren(part string) []*legacyNode {
+	nodes := make([]*legacyNode, 0)
+	for _, child := range n.children {
+		if child.part == part || child.isWild {
+			nodes = append(nodes, child)
+		}
+	}
+	return nodes
+}
+
+func (n *legacyNode) insert(pattern string, parts []string, height int) {
+	if len(parts) == height {
+		n.pattern = pattern
+		return
+	}
+
+	part := parts[height]
+	child := n.matchChild(part)
+	if child == nil {
+		child = &legacyNode{part: part, isWild: part[0] == ':' || part[0] == '*'}
+		n.children = append(n.children, child)
+	}
+	child.insert(pattern, parts, height+1)
+}
+
+func (n *legacyNode) search(parts []string, height int) *legacyNode {
+	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+		if n.pattern == "" {
+			return nil
+		}
+		return n
+	}
+
+	part := parts[height]
+	for _, child := range n.matchChildren(part) {
+		if result := child.search(parts, height+1); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+var benchPatterns = []string{
+	"/",
+	"/hello",
+	"/hello/:name",
+	"/hi/:name/doc",
+	"/assets/*filepath",
+	"/api/v1/users",
+	"/api/v1/users/:id",
+	"/api/v1/users/:id/posts",
+	"/api/v1/posts",
+	"/api/v1/posts/:id",
+	"/api/v1/comments",
+}
+
+var benchPaths = map[string]string{
+	"Static": "/api/v1/comments",
+	"Param":  "/api/v1/users/42/posts",
+}
+
+func BenchmarkLegacySearch(b *testing.B) {
+	root := &legacyNode{}
+	for _, pattern := range benchPatterns {
+		root.insert(pattern, parsePattern(pattern), 0)
+	}
+
+	for name, path := range benchPaths {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				root.search(parsePattern(path), 0)
+			}
+		})
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	root := &node{}
+	for _, pattern := range benchPatterns {
+		root.insert(pattern)
+	}
+
+	for name, path := range benchPaths {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				root.search(path)
+			}
+		})
+	}
+}
+
+func BenchmarkGetRoute(b *testing.B) {
+	r := newRouter()
+	for _, pattern := range benchPatterns {
+		r.addRoute("GET", pattern, nil)
+	}
+
+	for name, path := range benchPaths {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.getRoute("GET", path)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package gee
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// MultipartForm parses the multipart body and returns the form with its files. At most
+// Engine.MaxMultipartMemory bytes of file parts are kept in memory, the rest is streamed to
+// temp files that are removed once the request is served.
+// A body larger than the BodyLimit of the route aborts with 413.
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if err := c.Req.ParseMultipartForm(c.maxMultipartMemory()); err != nil {
+		if isTooLarge(err) {
+			c.AbortWithError(http.StatusRequestEntityTooLarge, err)
+		}
+		return nil, err
+	}
+
+	return c.Req.MultipartForm, nil
+}
+
+// FormFile returns the first file uploaded as name
+// example: curl http://localhost:9999/upload -F 'file=@report.pdf'
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+
+	if files := form.File[name]; len(files) > 0 {
+		return files[0], nil
+	}
+
+	return nil, http.ErrMissingFile
+}
+
+// SaveUploadedFile copies an uploaded file to dst, creating its directory if needed
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+func (c *Context) maxMultipartMemory() int64 {
+	if c.engine == nil {
+		return defaultMultipartMemory
+	}
+
+	return c.engine.MaxMultipartMemory
+}
+
+// BodyLimit returns a middleware that rejects request bodies larger than n bytes with 413,
+// example: r.POST("/upload", gee.BodyLimit(10<<20), upload)
+// A body announced as too large is rejected at once, a body without a Content-Length fails
+// to read past n bytes, Bind, MultipartForm, FormFile and PostForm then abort with 413.
+func BodyLimit(n int64) HandlerFunc {
+	return func(c *Context) {
+		if c.Req.ContentLength > n {
+			c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if c.Req.Body != nil {
+			c.Req.Body = http.MaxBytesReader(c.Writer, c.Req.Body, n)
+		}
+		c.Next()
+	}
+}
+
+// isTooLarge reports whether err comes from reading past the limit of BodyLimit
+func isTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,245 @@
+package gee
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError is a field that failed one of the rules of its binding tag
+type FieldError struct {
+	Field string      // the path of the field, e.g. Address.City or Items[0].Name
+	Rule  string      // the failed rule, e.g. min
+	Param string      // the parameter of the rule, e.g. 8 for min=8
+	Value interface{} // the value of the field
+}
+
+func (e *FieldError) Error() string {
+	rule := e.Rule
+	if e.Param != "" {
+		rule += "=" + e.Param
+	}
+
+	return "field " + e.Field + " failed on the '" + rule + "' rule"
+}
+
+// ValidationErrors are the fields of a struct that failed validation, returned by Validate and the Bind methods
+type ValidationErrors []*FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+
+	return "gee: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the fields of the struct obj points to against their binding tags and returns
+// the failures as ValidationErrors, nested structs and slices of structs are checked too.
+// The rules are separated by commas:
+//
+//	omitempty   the other rules are skipped when the field is the zero value, it must be the first rule
+//	required    the field is not the zero value, a slice or map is not empty
+//	min=n max=n the number is in range, or the length of a string, slice or map
+//	len=n       the length is n, or the number equals n
+//	oneof=a b   the value is one of the space separated values
+//	email       the string is an email address
+//	regex=re    the whole string matches re, it must be the last rule since re may contain commas
+//
+// The rules also run on empty fields, e.g. an age of 0 fails min=18, unless the tag starts with omitempty.
+// A nil pointer fails every rule but omitempty.
+// An unknown rule or a malformed parameter panics, as it is a bug in the struct definition.
+func Validate(obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var errs ValidationErrors
+	if v.Kind() == reflect.Struct {
+		errs = validateStruct(v, "", errs)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs ValidationErrors) ValidationErrors {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := prefix + sf.Name
+		field := v.Field(i)
+
+		if tag := sf.Tag.Get("binding"); tag != "" && tag != "-" {
+			errs = validateField(field, name, tag, errs)
+		}
+
+		errs = validateNested(field, name, errs)
+	}
+
+	return errs
+}
+
+// validateNested validates the structs inside field, e.g. a nested struct or a slice of structs
+func validateNested(field reflect.Value, name string, errs ValidationErrors) ValidationErrors {
+	for field.Kind() == reflect.Pointer || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return errs
+		}
+		field = field.Elem()
+	}
+
+	switch field.Kind() {
+	case reflect.Struct:
+		if field.Type() != timeType {
+			errs = validateStruct(field, name+".", errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < field.Len(); i++ {
+			errs = validateNested(field.Index(i), name+"["+strconv.Itoa(i)+"]", errs)
+		}
+	}
+
+	return errs
+}
+
+// validateField applies the rules of tag to field and appends the first failure to errs
+func validateField(field reflect.Value, name string, tag string, errs ValidationErrors) ValidationErrors {
+	omitEmpty := tag == "omitempty" || strings.HasPrefix(tag, "omitempty,")
+	if omitEmpty && field.IsZero() {
+		return errs
+	}
+
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "regex=") {
+			rule, tag = tag, ""
+		} else {
+			rule, tag, _ = strings.Cut(tag, ",")
+		}
+
+		rule, param, _ := strings.Cut(rule, "=")
+		if !checkRule(field, rule, param, name) {
+			return append(errs, &FieldError{Field: name, Rule: rule, Param: param, Value: field.Interface()})
+		}
+	}
+
+	return errs
+}
+
+// checkRule reports whether field passes rule
+func checkRule(field reflect.Value, rule string, param string, name string) bool {
+	for field.Kind() == reflect.Pointer && !field.IsNil() {
+		field = field.Elem()
+	}
+
+	if field.Kind() == reflect.Pointer {
+		return rule == "omitempty"
+	}
+
+	switch rule {
+	case "omitempty":
+		return true
+	case "required":
+		return !field.IsZero() && !(hasLen(field) && field.Len() == 0)
+	case "min":
+		return compare(field, param, name) >= 0
+	case "max":
+		return compare(field, param, name) <= 0
+	case "len":
+		return compare(field, param, name) == 0
+	case "oneof":
+		value := fmt.Sprint(field.Interface())
+		for _, option := range strings.Fields(param) {
+			if value == option {
+				return true
+			}
+		}
+		return false
+	case "email":
+		addr, err := mail.ParseAddress(field.String())
+		return err == nil && addr.Address == field.String()
+	case "regex":
+		return compileRule(param, name).MatchString(field.String())
+	}
+
+	panic("gee: unknown binding rule " + rule + " on field " + name)
+}
+
+// hasLen reports whether the rules compare the length of v rather than its value
+func hasLen(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+
+	return false
+}
+
+// compare compares the number field, or its length, with param and returns -1, 0 or 1
+func compare(field reflect.Value, param string, name string) int {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic("gee: invalid binding parameter " + param + " on field " + name)
+	}
+
+	var value float64
+	switch field.Kind() {
+	case reflect.String:
+		value = float64(utf8.RuneCountInString(field.String()))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		value = float64(field.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(field.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = float64(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		value = field.Float()
+	default:
+		panic("gee: binding rule needs a number or a length on field " + name)
+	}
+
+	switch {
+	case value < limit:
+		return -1
+	case value > limit:
+		return 1
+	}
+
+	return 0
+}
+
+// ruleRegexps caches the compiled regex rules, as the same struct is validated on every request
+var ruleRegexps sync.Map
+
+func compileRule(expr string, name string) *regexp.Regexp {
+	if re, ok := ruleRegexps.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		panic("gee: invalid binding regex " + expr + " on field " + name + ": " + err.Error())
+	}
+	ruleRegexps.Store(expr, re)
+
+	return re
+}
@@ -0,0 +1,48 @@
+package gee
+
+import "net/http"
+
+// WrapF turns an http.HandlerFunc into a HandlerFunc, e.g. r.GET("/debug/pprof/", gee.WrapF(pprof.Index))
+func WrapF(f http.HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		f(c.Writer, c.Req)
+	}
+}
+
+// WrapH turns an http.Handler into a HandlerFunc, e.g. r.GET("/metrics", gee.WrapH(promhttp.Handler()))
+func WrapH(h http.Handler) HandlerFunc {
+	return func(c *Context) {
+		h.ServeHTTP(c.Writer, c.Req)
+	}
+}
+
+// WrapMiddleware turns a standard func(http.Handler) http.Handler middleware into a HandlerFunc.
+// The rest of the chain runs as the wrapped handler, with the writer and request the middleware
+// passes to it. If the middleware does not call it, e.g. to reject the request, the chain stops.
+func WrapMiddleware(m func(http.Handler) http.Handler) HandlerFunc {
+	return func(c *Context) {
+		w, req := c.Writer, c.Req
+		called := false
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			called = true
+			c.Req = req
+			// keep tracking the response when the middleware wraps the writer, e.g. to compress it
+			if rw, ok := w.(ResponseWriter); ok {
+				c.Writer = rw
+			} else {
+				rw := &responseWriter{}
+				rw.reset(w)
+				c.Writer = rw
+				defer rw.WriteHeaderNow()
+			}
+			c.Next()
+		})
+		m(next).ServeHTTP(c.Writer, c.Req)
+
+		c.Writer, c.Req = w, req
+		if !called {
+			c.Abort()
+		}
+	}
+}
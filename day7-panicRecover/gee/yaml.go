@@ -0,0 +1,238 @@
+package gee
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// marshalYAML encodes v as a block style YAML document, without depending on a YAML package.
+// It supports maps, structs, slices and scalars, struct fields are named by their yaml tag or their
+// lower cased name and accept the omitempty option, values implementing encoding.TextMarshaler
+// (e.g. time.Time) are written as strings. Strings are double quoted when they would otherwise be
+// read back as another type or break the syntax.
+func marshalYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	rv := indirect(reflect.ValueOf(v))
+	if s, ok, err := yamlScalar(rv); err != nil {
+		return nil, err
+	} else if ok {
+		buf.WriteString(s + "\n")
+		return buf.Bytes(), nil
+	}
+
+	if err := yamlBlock(&buf, rv, 0); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// indirect follows pointers and interfaces, a nil one is returned as is
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() {
+		if v.Kind() == reflect.Pointer && v.Type().Implements(textMarshalerType) {
+			break
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// yamlScalar returns v on one line when it is not a non empty map, struct or slice
+func yamlScalar(v reflect.Value) (string, bool, error) {
+	if !v.IsValid() {
+		return "null", true, nil
+	}
+
+	if v.Type().Implements(textMarshalerType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return "null", true, nil
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return yamlString(string(text)), true, err
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return "null", true, nil // only nil ones are left by indirect
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return ".nan", true, nil
+		case math.IsInf(f, 1):
+			return ".inf", true, nil
+		case math.IsInf(f, -1):
+			return "-.inf", true, nil
+		}
+		return strconv.FormatFloat(f, 'g', -1, v.Type().Bits()), true, nil
+	case reflect.String:
+		return yamlString(v.String()), true, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return "null", true, nil
+		}
+		if v.Len() == 0 {
+			return "{}", true, nil
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return "null", true, nil
+		}
+		if v.Len() == 0 {
+			return "[]", true, nil
+		}
+	case reflect.Struct:
+		if len(yamlFields(v)) == 0 {
+			return "{}", true, nil
+		}
+	default:
+		return "", false, fmt.Errorf("gee: cannot encode %s as yaml", v.Type())
+	}
+
+	return "", false, nil
+}
+
+// yamlBlock writes the non empty map, struct or slice v with its lines indented by indent spaces
+func yamlBlock(buf *bytes.Buffer, v reflect.Value, indent int) error {
+	pad := strings.Repeat(" ", indent)
+
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := 0; i < v.Len(); i++ {
+			item := indirect(v.Index(i))
+			s, ok, err := yamlScalar(item)
+			if err != nil {
+				return err
+			}
+			if ok {
+				buf.WriteString(pad + "- " + s + "\n")
+				continue
+			}
+
+			// the first line of the nested block goes after the dash
+			var nested bytes.Buffer
+			if err := yamlBlock(&nested, item, indent+2); err != nil {
+				return err
+			}
+			buf.WriteString(pad + "- ")
+			buf.Write(nested.Bytes()[indent+2:])
+		}
+		return nil
+	}
+
+	for _, field := range yamlFields(v) {
+		buf.WriteString(pad + field.key + ":")
+
+		s, ok, err := yamlScalar(field.value)
+		if err != nil {
+			return err
+		}
+		if ok {
+			buf.WriteString(" " + s + "\n")
+			continue
+		}
+
+		buf.WriteString("\n")
+		if err := yamlBlock(buf, field.value, indent+2); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type yamlField struct {
+	key   string
+	value reflect.Value
+}
+
+// yamlFields returns the entries of a map sorted by key, or the fields of a struct in order
+func yamlFields(v reflect.Value) []yamlField {
+	var fields []yamlField
+
+	if v.Kind() == reflect.Map {
+		for _, key := range v.MapKeys() {
+			fields = append(fields, yamlField{yamlString(fmt.Sprint(key.Interface())), indirect(v.MapIndex(key))})
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+		return fields
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+
+		value := v.Field(i)
+		if opts == "omitempty" && value.IsZero() {
+			continue
+		}
+		fields = append(fields, yamlField{yamlString(name), indirect(value)})
+	}
+
+	return fields
+}
+
+// YAML 1.1 reads these plain scalars as timestamps and base 60 numbers, example: 2001-12-14, 12:30
+var (
+	yamlTimestamp   = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}([Tt \t]|$)`)
+	yamlSexagesimal = regexp.MustCompile(`^[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+(\.[0-9_]*)?$`)
+)
+
+// yamlString returns s plain when YAML reads it back as the same string, double quoted otherwise
+func yamlString(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return strconv.Quote(s)
+	}
+
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~", ".nan", ".inf", "-.inf":
+		return strconv.Quote(s)
+	}
+
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	if yamlTimestamp.MatchString(s) || yamlSexagesimal.MatchString(s) {
+		return strconv.Quote(s)
+	}
+
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || r == '\ufeff' {
+			return strconv.Quote(s)
+		}
+	}
+
+	return s
+}